@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -30,17 +35,42 @@ var rootFlags struct {
 	PrintListOfFiles bool
 	ConsiderAllEqual bool
 	Match            string
+	Author           string
+	Source           string
 	LongestShort     int
 	LongDictumsOnly  bool
 	ShortOnly        bool
 	IgnoreCase       bool
 	Wait             bool
+	Unrotated        bool
+	AutoIndex        bool
+	StaleIndex       string
+	Seed             uint64
+	ShowSeed         bool
+	CryptoRand       bool
+	Daily            bool
+	Timezone         string
+	Salt             string
+	NoRepeat         int
+	NoRepeatDays     int
+	ShuffleBag       bool
+	HistoryPath      string
+	Next             bool
+	Prev             bool
+	Reset            bool
+	Goto             uint64
+	CursorsPath      string
+	Strategy         string
 }
 
 var RootCmd = &cobra.Command{
 	Use:   "gofortune",
 	Short: "Print a random, hopefully interesting, adage",
 	Long:  `When fortune is run with no arguments it prints out a random epigram`,
+	// The arguments are fortune paths, optionally weighted N%, instead of
+	// unknown subcommands. A first path named after a subcommand, such as
+	// fsck, still runs it; ./fsck reads the collection.
+	Args: cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		request, err := fortune.PrepareRequest(args, defaultFortunePath, defaultOffensiveFortunePath)
 		if err != nil {
@@ -53,11 +83,46 @@ var RootCmd = &cobra.Command{
 		request.PrintListOfFiles = rootFlags.PrintListOfFiles
 		request.ConsiderAllEqual = rootFlags.ConsiderAllEqual
 		request.Match = rootFlags.Match
+		request.Author = rootFlags.Author
+		request.Source = rootFlags.Source
 		request.LongestShort = rootFlags.LongestShort
 		request.LongDictumsOnly = rootFlags.LongDictumsOnly
 		request.ShortOnly = rootFlags.ShortOnly
 		request.IgnoreCase = rootFlags.IgnoreCase
 		request.Wait = rootFlags.Wait
+		request.Unrotated = rootFlags.Unrotated
+		request.AutoIndex = rootFlags.AutoIndex
+		request.Seed = rootFlags.Seed
+		request.Seeded = cmd.Flags().Changed("seed")
+		request.ShowSeed = rootFlags.ShowSeed
+		request.CryptoRand = rootFlags.CryptoRand
+		request.Daily = rootFlags.Daily
+		request.Timezone = rootFlags.Timezone
+		request.Salt = rootFlags.Salt
+		request.NoRepeat = rootFlags.NoRepeat
+		request.NoRepeatDays = rootFlags.NoRepeatDays
+		request.ShuffleBag = rootFlags.ShuffleBag
+		request.HistoryPath = rootFlags.HistoryPath
+		request.Next = rootFlags.Next
+		request.Prev = rootFlags.Prev
+		request.Reset = rootFlags.Reset
+		request.Goto = cmd.Flags().Changed("goto")
+		request.GotoEntry = rootFlags.Goto
+		request.CursorsPath = rootFlags.CursorsPath
+		request.StaleIndex, err = fortune.ParseStaleIndexPolicy(rootFlags.StaleIndex)
+		if err != nil {
+			return err
+		}
+		request.Strategy, err = fortune.ParseSelectionStrategy(rootFlags.Strategy)
+		if err != nil {
+			return err
+		}
+		if request.ConsiderAllEqual {
+			if cmd.Flags().Changed("strategy") && request.Strategy != fortune.SelectFilesEqually {
+				return fmt.Errorf("-e cannot be combined with --strategy %s", request.Strategy)
+			}
+			request.Strategy = fortune.SelectFilesEqually
+		}
 
 		return fortuneRun(request)
 	},
@@ -84,13 +149,34 @@ func init() {
 	f.BoolVarP(&rootFlags.Offensive, "offensive", "o", false, "Choose only from potentially offensive aphorisms")
 	f.BoolVarP(&rootFlags.ShowCookieFile, "showCookieFile", "c", false, "Show the cookie file from which the fortune came")
 	f.BoolVarP(&rootFlags.PrintListOfFiles, "printListOfFiles", "f", false, "Print out the list of files which would be searched, but don't print a fortune")
-	f.BoolVarP(&rootFlags.ConsiderAllEqual, "considerAllEqual", "e", false, "Consider all fortune files to be of equal size")
+	f.BoolVarP(&rootFlags.ConsiderAllEqual, "considerAllEqual", "e", false, "Consider all fortune files to be of equal size, the same as --strategy equal")
+	f.StringVar(&rootFlags.Strategy, "strategy", fortune.SelectBySize.String(), "How likely fortune files are to be chosen: by their size, every file equally likely (equal) or every fortune equally likely (fortune); in all three, the files of a path weighted N% share exactly N%")
 	f.StringVarP(&rootFlags.Match, "match", "m", "", "Print out all fortunes which match the regular expression pattern")
+	f.StringVar(&rootFlags.Author, "author", "", "Choose only from fortunes whose \"-- Author, Work\" attribution names an author matching the regular expression pattern")
+	f.StringVar(&rootFlags.Source, "source", "", "Choose only from fortunes whose attribution names a work matching the regular expression pattern")
 	f.IntVarP(&rootFlags.LongestShort, "longestShort", "n", 160, "set the longest fortune length (in characters) considered to be \"short\" (the default is 160)")
 	f.BoolVarP(&rootFlags.LongDictumsOnly, "longDictumsOnly", "l", false, "Long dictums only. See -n on how \"long\" is enough")
 	f.BoolVarP(&rootFlags.ShortOnly, "shortOnly", "s", false, "Short apothegms only. See -n on which fortunes are considered \"short\"")
-	f.BoolVarP(&rootFlags.IgnoreCase, "ignoreCase", "i", false, "Ignore case for -m patterns")
+	f.BoolVarP(&rootFlags.IgnoreCase, "ignoreCase", "i", false, "Ignore case for -m, --author and --source patterns")
 	f.BoolVarP(&rootFlags.Wait, "wait", "w", false, "Wait before termination for an amount of time calculated from the number of characters in the message")
+	f.BoolVarP(&rootFlags.Unrotated, "unrotated", "u", false, "Print rot13'd fortunes as stored instead of decoding them")
+	f.BoolVar(&rootFlags.AutoIndex, "autoIndex", fortune.DefaultLoadOptions.AutoIndex, "Index fortune files that have no .dat file in memory instead of ignoring them")
+	f.StringVar(&rootFlags.StaleIndex, "staleIndex", fortune.DefaultLoadOptions.StaleIndex.String(), "What to do with .dat files older than their fortune file: reindex (in memory), warn or rewrite")
+	f.Uint64Var(&rootFlags.Seed, "seed", 0, "Pick reproducibly from this seed: the same seed and fortune files always give the same fortune")
+	f.BoolVar(&rootFlags.ShowSeed, "showSeed", false, "Print the seed of the pick to standard error, so it can be repeated with --seed")
+	f.BoolVar(&rootFlags.CryptoRand, "cryptoRand", false, "Pick with the operating system's cryptographic random number generator")
+	f.BoolVar(&rootFlags.Daily, "daily", false, "Print the fortune of the day, the same on every host with the same fortune files")
+	f.StringVar(&rootFlags.Timezone, "timezone", "", "With --daily, the IANA time zone, such as Europe/Paris, whose calendar day is used (the default is the local one)")
+	f.StringVar(&rootFlags.Salt, "salt", "", "With --daily, pick a different fortune of the day than hosts using another salt")
+	f.IntVar(&rootFlags.NoRepeat, "noRepeat", 0, "Do not repeat any of the last N fortunes shown")
+	f.IntVar(&rootFlags.NoRepeatDays, "noRepeatDays", 0, "Do not repeat any fortune shown in the last N days")
+	f.BoolVar(&rootFlags.ShuffleBag, "shuffleBag", false, "Show every fortune once before repeating any")
+	f.BoolVar(&rootFlags.Next, "next", false, "Print the fortune of the collection following the one printed last with --next, --prev or --goto, reading it in order across runs")
+	f.BoolVar(&rootFlags.Prev, "prev", false, "Print the fortune of the collection preceding the one printed last with --next, --prev or --goto")
+	f.BoolVar(&rootFlags.Reset, "reset", false, "Forget where --next is in the collection, so it starts over from the first fortune")
+	f.Uint64Var(&rootFlags.Goto, "goto", 0, "Print the N-th fortune of the collection, counting from 1, and continue --next from there")
+	f.StringVar(&rootFlags.CursorsPath, "cursors", "", "The file recording where --next is in each collection (the default is gofortune/cursors.json in $XDG_STATE_HOME)")
+	f.StringVar(&rootFlags.HistoryPath, "history", "", "The file recording the fortunes shown for --noRepeat, --noRepeatDays and --shuffleBag (the default is gofortune/history.json in $XDG_STATE_HOME)")
 }
 
 func fortuneRun(request fortune.Request) error {
@@ -116,32 +202,178 @@ func fortuneRun(request fortune.Request) error {
 		longerThan = uint32(request.LongestShort)
 	}
 
-	rootFsDescriptor, err := fortune.LoadPaths(input, shorterThan, longerThan)
+	random, err := newRand(request)
+	if err != nil {
+		return err
+	}
+
+	options := fortune.LoadOptions{
+		AutoIndex:  request.AutoIndex,
+		StaleIndex: request.StaleIndex,
+		Warn:       func(err error) { fmt.Fprintln(os.Stderr, err) },
+	}
+	filter, err := fortune.NewAttributionFilter(request.Author, request.Source, request.IgnoreCase)
+	if err != nil {
+		return err
+	}
+	rootFsDescriptor, err := fortune.LoadPathsWithOptions(input, shorterThan, longerThan, options)
 	if err != nil {
 		return err
 	}
 
+	fortune.SetProbabilitiesWithStrategy(&rootFsDescriptor, request.Strategy)
+	if !filter.IsZero() {
+		rootFsDescriptor, err = fortune.FilterByAttribution(rootFsDescriptor, filter)
+		if err != nil {
+			return err
+		}
+	}
+
 	if request.Match != "" {
 		matchedFortunesChannel, errorChannel := fortune.GetFortunesMatching(rootFsDescriptor, request.Match, request.IgnoreCase)
 		printFortuneChannels(request, matchedFortunesChannel, errorChannel)
 		return nil
 	}
 
-	fortune.SetProbabilities(&rootFsDescriptor, request.ConsiderAllEqual)
-
 	if request.PrintListOfFiles {
-		printListOfFiles(rootFsDescriptor)
+		printListOfFiles(os.Stdout, rootFsDescriptor)
+		return nil
+	}
+
+	if request.Next || request.Prev || request.Reset || request.Goto {
+		return moveCursor(request, input, rootFsDescriptor)
+	}
+
+	if request.NoRepeat < 0 || request.NoRepeatDays < 0 {
+		return errors.New("--noRepeat and --noRepeatDays must not be negative")
+	}
+	window := fortune.HistoryWindow{Last: request.NoRepeat, Days: request.NoRepeatDays, ShuffleBag: request.ShuffleBag}
+	if window.IsZero() {
+		output, err := fortune.GetLengthFilteredRandomFortuneWith(rootFsDescriptor, shorterThan, longerThan, random)
+		if err != nil {
+			return err
+		}
+		printFortune(request, output, nil)
 		return nil
 	}
 
-	output, err := fortune.GetLengthFilteredRandomFortune(rootFsDescriptor, shorterThan, longerThan)
+	historyPath := request.HistoryPath
+	if historyPath == "" {
+		if historyPath, err = fortune.DefaultHistoryPath(); err != nil {
+			return err
+		}
+	}
+	history, err := fortune.LoadHistoryFromPath(historyPath)
+	if err != nil {
+		return err
+	}
+	output, err := fortune.GetUnseenRandomFortuneWith(rootFsDescriptor, shorterThan, longerThan, &history, window, time.Now(), random)
 	if err != nil {
 		return err
 	}
+	if err := fortune.SaveHistoryToPath(historyPath, history); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
 	printFortune(request, output, nil)
 	return nil
 }
 
+// moveCursor prints the fortune of the collection made of input that
+// request moves its cursor to, or resets the cursor.
+func moveCursor(request fortune.Request, input []fortune.ProbabilityPath, rootFsDescriptor fortune.FileSystemNodeDescriptor) error {
+	moves := 0
+	for _, move := range []bool{request.Next, request.Prev, request.Reset, request.Goto} {
+		if move {
+			moves++
+		}
+	}
+	if moves > 1 {
+		return errors.New("only one of --next, --prev, --reset and --goto can be given")
+	}
+	if request.Goto && request.GotoEntry == 0 {
+		return errors.New("--goto counts fortunes from 1")
+	}
+
+	cursorsPath := request.CursorsPath
+	if cursorsPath == "" {
+		var err error
+		if cursorsPath, err = fortune.DefaultCursorsPath(); err != nil {
+			return err
+		}
+	}
+	cursors, err := fortune.LoadCursorsFromPath(cursorsPath)
+	if err != nil {
+		return err
+	}
+
+	key := fortune.CollectionKey(input)
+	var output fortune.Cookie
+	switch {
+	case request.Reset:
+		cursors.Reset(key)
+	case request.Prev:
+		output, err = cursors.Prev(rootFsDescriptor, key)
+	case request.Goto:
+		output, err = cursors.Goto(rootFsDescriptor, key, request.GotoEntry-1)
+	default:
+		output, err = cursors.Next(rootFsDescriptor, key)
+	}
+	if err != nil {
+		return err
+	}
+	if err := fortune.SaveCursorsToPath(cursorsPath, cursors); err != nil {
+		return fmt.Errorf("save cursors: %w", err)
+	}
+	if !request.Reset {
+		printFortune(request, output, nil)
+	}
+	return nil
+}
+
+// newRand returns the generator request picks from: the one of the fortune
+// of the day with request.Daily, seeded from request.Seed, or a fresh seed
+// when it asks to show one, drawn from crypto/rand with request.CryptoRand,
+// and otherwise nil for the default generator.
+func newRand(request fortune.Request) (*rand.Rand, error) {
+	if request.Daily {
+		if request.Seeded || request.ShowSeed || request.CryptoRand {
+			return nil, errors.New("--daily cannot be combined with --seed, --showSeed or --cryptoRand")
+		}
+		if request.NoRepeat != 0 || request.NoRepeatDays != 0 || request.ShuffleBag {
+			return nil, errors.New("--daily cannot be combined with --noRepeat, --noRepeatDays or --shuffleBag")
+		}
+		location := time.Local
+		if request.Timezone != "" {
+			var err error
+			if location, err = time.LoadLocation(request.Timezone); err != nil {
+				return nil, fmt.Errorf("invalid time zone: %w", err)
+			}
+		}
+		return fortune.NewDailyRand(time.Now().In(location), request.Salt), nil
+	}
+	if request.CryptoRand {
+		if request.Seeded || request.ShowSeed {
+			return nil, errors.New("--cryptoRand cannot be combined with --seed or --showSeed")
+		}
+		return fortune.NewCryptoRand(), nil
+	}
+	if !request.Seeded && !request.ShowSeed {
+		return nil, nil
+	}
+	seed := request.Seed
+	if !request.Seeded {
+		var buffer [8]byte
+		if _, err := cryptorand.Read(buffer[:]); err != nil {
+			return nil, fmt.Errorf("generate seed: %w", err)
+		}
+		seed = binary.LittleEndian.Uint64(buffer[:])
+	}
+	if request.ShowSeed {
+		fmt.Fprintf(os.Stderr, "seed: %d\n", seed)
+	}
+	return fortune.NewSeededRand(seed), nil
+}
+
 // printFortuneChannels drains both the fortune and error channels
 // concurrently using a single select loop. Draining them sequentially would
 // risk deadlocking the producer if it blocks trying to send on a channel
@@ -173,22 +405,36 @@ func printFortune(request fortune.Request, cookie fortune.Cookie, err error) {
 	if request.ShowCookieFile {
 		fmt.Printf("(%s)\n%%\n", cookie.FileName)
 	}
-	fmt.Println(cookie.Data)
+	if request.Unrotated {
+		fmt.Println(cookie.RawData())
+	} else {
+		fmt.Println(cookie.Data)
+	}
 	if request.Wait {
 		readTimeWait(len(cookie.Data))
 	}
 }
 
-func printListOfFiles(directoryDescriptor fortune.FileSystemNodeDescriptor) {
+// printListOfFiles prints to w the probability of each path and of the files
+// in it, followed for files by the probability of each of their fortunes.
+func printListOfFiles(w io.Writer, directoryDescriptor fortune.FileSystemNodeDescriptor) {
 	for i := range directoryDescriptor.Children {
-		fmt.Printf("%5.2f%% %s\n", directoryDescriptor.Children[i].Percent, directoryDescriptor.Children[i].Path)
+		printFileProbability(w, directoryDescriptor.Children[i], directoryDescriptor.Children[i].Path)
 		for j := range directoryDescriptor.Children[i].Children {
-			fmt.Printf("%*s", 4, "")
-			fmt.Printf("%5.2f%% %s\n", directoryDescriptor.Children[i].Children[j].Percent, filepath.Base(directoryDescriptor.Children[i].Children[j].Path))
+			fmt.Fprintf(w, "%*s", 4, "")
+			printFileProbability(w, directoryDescriptor.Children[i].Children[j], filepath.Base(directoryDescriptor.Children[i].Children[j].Path))
 		}
 	}
 }
 
+func printFileProbability(w io.Writer, fsDescriptor fortune.FileSystemNodeDescriptor, name string) {
+	if len(fsDescriptor.Children) > 0 {
+		fmt.Fprintf(w, "%5.2f%% %s\n", fsDescriptor.Percent, name)
+		return
+	}
+	fmt.Fprintf(w, "%5.2f%% %s (%.4g%% per fortune)\n", fsDescriptor.Percent, name, fsDescriptor.FortuneProbability())
+}
+
 func readTimeWait(length int) {
 	timeWait := pkg.Max(uint32(length/charsPerSec), uint32(minimumWaitSeconds))
 	time.Sleep(time.Second * time.Duration(timeWait))
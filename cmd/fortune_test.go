@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/spf13/cobra"
 	"github.com/vromero/gofortune/pkg/fortune"
 )
 
@@ -87,7 +92,10 @@ func TestRootCmdFlagsRegistered(t *testing.T) {
 	expected := []string{
 		"allMaxims", "offensive", "showCookieFile", "printListOfFiles",
 		"considerAllEqual", "match", "longestShort", "longDictumsOnly",
-		"shortOnly", "ignoreCase", "wait",
+		"shortOnly", "ignoreCase", "wait", "unrotated",
+		"autoIndex", "staleIndex", "author", "source", "seed", "showSeed", "cryptoRand",
+		"daily", "timezone", "salt", "noRepeat", "noRepeatDays", "shuffleBag", "history",
+		"next", "prev", "reset", "goto", "cursors", "strategy",
 	}
 	for _, name := range expected {
 		if f := RootCmd.Flags().Lookup(name); f == nil {
@@ -142,3 +150,82 @@ func TestFortuneRunRejectsMissingPath(t *testing.T) {
 		t.Fatal("expected error for missing path, got nil")
 	}
 }
+
+// TestRootCmdPositionalPaths verifies that RootCmd takes its positional
+// arguments as fortune paths rather than unknown subcommands, except for a
+// first one named after a subcommand, which runs it unless written as a path.
+func TestRootCmdPositionalPaths(t *testing.T) {
+	t.Cleanup(func() {
+		RootCmd.SetArgs(nil)
+		RootCmd.SetOut(nil)
+		RootCmd.SetErr(nil)
+	})
+	// The failure is expected; its usage text is not worth printing.
+	RootCmd.SetOut(io.Discard)
+	RootCmd.SetErr(io.Discard)
+
+	missing := filepath.Join(t.TempDir(), "does-not-exist")
+	RootCmd.SetArgs([]string{"30%", missing})
+	if err := RootCmd.Execute(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the path to be loaded and found missing, got %v", err)
+	}
+
+	for _, test := range []struct {
+		args []string
+		want *cobra.Command
+	}{
+		{[]string{"fortunes"}, RootCmd},
+		{[]string{"30%", "fsck"}, RootCmd},
+		{[]string{"./fsck"}, RootCmd},
+		{[]string{"fsck"}, fsckCmd},
+		{[]string{"inspect"}, inspectCmd},
+		{[]string{"strfile"}, strfileCmd},
+		{[]string{"unstr"}, unstrCmd},
+	} {
+		found, args, err := RootCmd.Find(test.args)
+		if err == nil && found == RootCmd {
+			err = found.ValidateArgs(args)
+		}
+		if err != nil || found != test.want {
+			t.Errorf("%q: expected %q, got %q (err=%v)", test.args, test.want.Name(), found.Name(), err)
+		}
+	}
+}
+
+// TestPrintListOfFilesWeighted verifies that -f prints exactly the N% of
+// each weighted path, whatever the strategy, and what the weights leave for
+// the others.
+func TestPrintListOfFilesWeighted(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, entries int) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("fortune\n%\n", entries)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("a/one", 3)
+	write("a/two", 9)
+	b, c := write("b", 5), write("c", 40)
+	paths := []fortune.ProbabilityPath{{Path: filepath.Join(dir, "a"), Percentage: 30}, {Path: b, Percentage: 20}, {Path: c}}
+
+	for _, strategy := range []fortune.SelectionStrategy{fortune.SelectBySize, fortune.SelectFilesEqually, fortune.SelectFortunesEqually} {
+		t.Run(strategy.String(), func(t *testing.T) {
+			root, err := fortune.LoadPaths(paths, 1000, 0)
+			if err != nil {
+				t.Fatalf("load paths: %v", err)
+			}
+			fortune.SetProbabilitiesWithStrategy(&root, strategy)
+			var output bytes.Buffer
+			printListOfFiles(&output, root)
+			for _, want := range []string{"30.00% " + paths[0].Path + "\n", "20.00% " + b + " ", "50.00% " + c + " "} {
+				if !strings.Contains(output.String(), want) {
+					t.Errorf("expected %q in:\n%s", want, output.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vromero/gofortune/pkg/strfile"
+)
+
+var fsckName = "fsck"
+var fsckShortDescription = "Check that fortune files and their indexes agree"
+var fsckLongDescription = `fsck re-scans each datafile and checks its index, datafile.dat, against it: every offset must start a
+string, each string must be listed once, the string count, longest and shortest lengths and flags in the header
+must match the strings and their order, and the lengths and checksum recorded by GoFortune's strfile must still
+hold. Each discrepancy is printed on its own line, or as JSON with --json, and fsck exits with an error if any
+is found.`
+
+var fsckJSON bool
+
+// ErrReported is wrapped by the errors of commands whose output already
+// reports why they failed, such as the report of fsck; they exit with an
+// error without printing it again.
+var ErrReported = errors.New("failure reported")
+
+var fsckCmd = &cobra.Command{
+	Use:   fsckName + " <datafile>...",
+	Short: fsckShortDescription,
+	Long:  fsckLongDescription,
+	Args:  cobra.MinimumNArgs(1),
+	// A failed check is described by the report, which the usage text would
+	// bury.
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var files [][2]string
+		for _, arg := range args {
+			sourceFile, dataFile := unstrPaths(arg)
+			files = append(files, [2]string{sourceFile, dataFile})
+		}
+		return silenceReported(cmd, verifyRun(os.Stdout, files, fsckJSON))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(fsckCmd)
+	fsckCmd.Flags().BoolVar(&fsckJSON, "json", false, "Print one JSON report per file instead of a line per discrepancy")
+}
+
+// silenceReported returns err, keeping cobra from printing it when it wraps
+// ErrReported.
+func silenceReported(cmd *cobra.Command, err error) error {
+	if errors.Is(err, ErrReported) {
+		cmd.SilenceErrors = true
+	}
+	return err
+}
+
+// verifyRun verifies each pair of source and data files and reports the
+// discrepancies found to w, as text lines or as one JSON report per file. It
+// fails with ErrReported when any index does not match its file.
+func verifyRun(w io.Writer, files [][2]string, asJSON bool) error {
+	encoder := json.NewEncoder(w)
+	failed := 0
+	for _, file := range files {
+		report, err := strfile.Verify(file[0], file[1])
+		if err != nil {
+			return err
+		}
+		if !report.OK() {
+			failed++
+		}
+		if asJSON {
+			err = encoder.Encode(report)
+		} else {
+			_, err = report.WriteTo(w)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d indexes do not match their data file: %w", failed, len(files), ErrReported)
+	}
+	return nil
+}
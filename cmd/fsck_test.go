@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vromero/gofortune/pkg/strfile"
+)
+
+// TestVerifyRunReported verifies that a failed check leaves only the JSON
+// report on the output and fails with ErrReported, so that neither the
+// usage text nor the error are printed after it.
+func TestVerifyRunReported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fortunes")
+	if err := os.WriteFile(path, []byte("a\n%\nb\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strfile.StrFile(path, path+".dat", strfile.Options{}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("a\n%\nb\n%\nc\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	err := verifyRun(&output, [][2]string{{path, path + ".dat"}}, true)
+	if !errors.Is(err, ErrReported) {
+		t.Fatalf("expected ErrReported, got %v", err)
+	}
+	var report strfile.VerifyReport
+	decoder := json.NewDecoder(&output)
+	if err := decoder.Decode(&report); err != nil || decoder.More() {
+		t.Errorf("expected a single JSON report, got %q (err=%v)", output.String(), err)
+	}
+	if !fsckCmd.SilenceUsage || !strfileCmd.SilenceUsage {
+		t.Error("expected fsck and strfile not to print their usage on failure")
+	}
+}
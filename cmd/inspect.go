@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vromero/gofortune/pkg/strfile"
+)
+
+var inspectName = "inspect"
+var inspectShortDescription = "Print the header and offset table of an index file"
+var inspectLongDescription = `inspect decodes the index of datafile, datafile.dat, and prints its version and layout, the number of
+strings, the longest and shortest lengths, the flags and the delimiting character. With --offsets it also lists
+the offset of every string, with the first bytes of each string when --preview is greater than zero.`
+
+var inspectFlags struct {
+	Offsets bool
+	Preview int
+	JSON    bool
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   inspectName + " <datafile>",
+	Short: inspectShortDescription,
+	Long:  inspectLongDescription,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sourceFile, dataFile := unstrPaths(args[0])
+		options := strfile.InspectOptions{Offsets: inspectFlags.Offsets, PreviewLength: inspectFlags.Preview}
+		if inspectFlags.Preview > 0 {
+			options.SourceFile = sourceFile
+		}
+
+		info, err := strfile.Inspect(dataFile, options)
+		if err != nil {
+			return err
+		}
+		if inspectFlags.JSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(info)
+		}
+		_, err = info.WriteTo(os.Stdout)
+		return err
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().BoolVar(&inspectFlags.Offsets, "offsets", false, "List the offset table")
+	inspectCmd.Flags().IntVar(&inspectFlags.Preview, "preview", 0, "With --offsets, show up to this many bytes of each string")
+	inspectCmd.Flags().BoolVar(&inspectFlags.JSON, "json", false, "Print the index as JSON")
+}
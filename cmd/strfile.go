@@ -10,32 +10,81 @@ import (
 
 type StrFileRequest struct {
 	DelimitingChar, SourceFile, DataFile        string
+	Locale                                      string
+	Seed                                        int64
+	Seeded                                      bool
 	IgnoreCase, Silent, Order, Randomize, Rot13 bool
+	LargeFile, Recursive, Verify, JSON          bool
+	Metadata                                    bool
+	Jobs                                        int
 }
 
 var strFileCmdRequest = StrFileRequest{}
 
+// options returns the strfile.Options the request asks for.
+func (request StrFileRequest) options() strfile.Options {
+	return strfile.Options{
+		DelimitingChar: request.DelimitingChar,
+		IgnoreCase:     request.IgnoreCase,
+		Order:          request.Order,
+		Locale:         request.Locale,
+		Seeded:         request.Seeded,
+		Seed:           request.Seed,
+		Randomize:      request.Randomize,
+		Rot13:          request.Rot13,
+		LargeFile:      request.LargeFile,
+		Metadata:       request.Metadata,
+	}
+}
+
 var strFileName = "strfile"
 var strFileShortDescription = "Create a random access index file for storing string"
 var strFileLongDescription = `strfile reads a file containing groups of lines separated by a line containing a
 single percent '%' sign (or other specified delimiter character) and creates a data file which contains a header
 structure and a table of file offsets for each group of lines. This allows random access of the strings.
-The output file, if not specified on the command line, is named sourcefile.dat.`
+The output file, if not specified on the command line, is named sourcefile.dat.
+
+With --recursive the arguments are directories: every fortune file found under them whose index is missing or
+out of date is indexed into a data file named after it with .dat appended, several files at a time.`
 
 var strfileCmd = &cobra.Command{
 	Use:   strFileName,
 	Short: strFileShortDescription,
 	Long:  strFileLongDescription,
 	Args:  cobra.MinimumNArgs(1),
+	// Failures are reported without the usage text, which would bury the
+	// --verify report.
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// Options take an empty delimiter for the default one, which an
+		// explicit -c '' must not silently get.
+		if cmd.Flags().Changed("delimitingChar") && strFileCmdRequest.DelimitingChar == "" {
+			return pkg.ValidateDelimiter("")
+		}
+		if cmd.Flags().Changed("seed") {
+			strFileCmdRequest.Seeded = true
+		} else if epoch, ok, err := strfile.SourceDateEpoch(); err != nil {
+			return err
+		} else if ok {
+			strFileCmdRequest.Seeded, strFileCmdRequest.Seed = true, epoch
+		}
+		if strFileCmdRequest.Recursive {
+			return strFileTreeRun(strFileCmdRequest, args)
+		}
 		strFileCmdRequest.SourceFile = args[0]
 		if len(args) > 1 {
 			strFileCmdRequest.DataFile = args[1]
+		} else if pkg.IsCompressedFileName(args[0]) {
+			// Keep the compression suffix so fortune finds the index
+			// next to the file.
+			strFileCmdRequest.DataFile = args[0] + ".dat"
 		} else {
 			strFileCmdRequest.DataFile = pkg.RemoveFileExtension(args[0]) + ".dat"
 		}
-		summary, err := strfile.StrFile(strFileCmdRequest.IgnoreCase, strFileCmdRequest.Order, strFileCmdRequest.Randomize, strFileCmdRequest.Rot13,
-			strFileCmdRequest.DelimitingChar, strFileCmdRequest.SourceFile, strFileCmdRequest.DataFile)
+		if strFileCmdRequest.Verify {
+			return silenceReported(cmd, verifyRun(os.Stdout, [][2]string{{strFileCmdRequest.SourceFile, strFileCmdRequest.DataFile}}, strFileCmdRequest.JSON))
+		}
+		summary, err := strfile.StrFile(strFileCmdRequest.SourceFile, strFileCmdRequest.DataFile, strFileCmdRequest.options())
 		if err != nil {
 			return err
 		}
@@ -50,10 +99,43 @@ var strfileCmd = &cobra.Command{
 
 func init() {
 	RootCmd.AddCommand(strfileCmd)
-	strfileCmd.Flags().StringVarP(&strFileCmdRequest.DelimitingChar, "delimitingChar", "c", "%", "Change the delimiting character from the percent sign to DelimitingChar")
+	strfileCmd.Flags().StringVarP(&strFileCmdRequest.DelimitingChar, "delimitingChar", "c", "%", "Change the delimiter from the percent sign to DelimitingChar, any UTF-8 string such as § or -- 8< --")
 	strfileCmd.Flags().BoolVarP(&strFileCmdRequest.IgnoreCase, "ignoreCase", "i", false, "Ignore case when ordering the strings")
 	strfileCmd.Flags().BoolVarP(&strFileCmdRequest.Silent, "silent", "s", false, "Run silently")
 	strfileCmd.Flags().BoolVarP(&strFileCmdRequest.Order, "order", "o", false, "Order the strings in alphabetical Order")
+	strfileCmd.Flags().StringVar(&strFileCmdRequest.Locale, "locale", "", "With --order, sort with the collation of this language (a tag such as de or sv) instead of by code point")
 	strfileCmd.Flags().BoolVarP(&strFileCmdRequest.Randomize, "randomize", "n", false, "Randomize access to the strings")
+	strfileCmd.Flags().Int64Var(&strFileCmdRequest.Seed, "seed", 0, "Build a reproducible index, recording no modification time; with --randomize, shuffle from this seed (defaults to $SOURCE_DATE_EPOCH when set)")
 	strfileCmd.Flags().BoolVarP(&strFileCmdRequest.Rot13, "rot13", "x", false, "Rotate 13 positions in a simple caesar cypher")
+	strfileCmd.Flags().BoolVarP(&strFileCmdRequest.LargeFile, "largeFile", "l", false, "Write a version 3 index with 64-bit offsets, needed for data files of 4 GiB or more")
+	strfileCmd.Flags().BoolVar(&strFileCmdRequest.Metadata, "metadata", false, "Also write a .meta file listing the author, source and year of each string, parsed from its \"-- Author, Work\" trailer")
+	strfileCmd.Flags().BoolVar(&strFileCmdRequest.Verify, "verify", false, "Check the existing data file against the source file instead of writing it")
+	strfileCmd.Flags().BoolVar(&strFileCmdRequest.JSON, "json", false, "Print the --verify report as JSON")
+	strfileCmd.Flags().BoolVar(&strFileCmdRequest.Recursive, "recursive", false, "Index every fortune file under the given directories that lacks an up to date index")
+	strfileCmd.Flags().IntVarP(&strFileCmdRequest.Jobs, "jobs", "j", 0, "Number of files indexed at once with --recursive (default one per CPU)")
+}
+
+// strFileTreeRun indexes the directory trees in roots, reporting the
+// aggregated result and failing when any file could not be indexed.
+func strFileTreeRun(request StrFileRequest, roots []string) error {
+	options := strfile.BatchOptions{Options: request.options(), Workers: request.Jobs}
+
+	var total strfile.BatchSummary
+	for _, root := range roots {
+		summary, err := strfile.StrFileTree(root, options)
+		if err != nil {
+			return err
+		}
+		total.Indexed = append(total.Indexed, summary.Indexed...)
+		total.UpToDate = append(total.UpToDate, summary.UpToDate...)
+		total.Skipped = append(total.Skipped, summary.Skipped...)
+		total.Failed = append(total.Failed, summary.Failed...)
+	}
+
+	if !request.Silent {
+		if _, err := total.WriteTo(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return total.Err()
 }
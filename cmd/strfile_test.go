@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// TestStrfileRejectsEmptyDelimiter verifies that an explicitly empty -c is
+// rejected instead of standing for the default delimiter.
+func TestStrfileRejectsEmptyDelimiter(t *testing.T) {
+	saved := strFileCmdRequest
+	t.Cleanup(func() {
+		strFileCmdRequest = saved
+		strfileCmd.Flags().Lookup("delimitingChar").Changed = false
+		RootCmd.SetArgs(nil)
+	})
+
+	path := filepath.Join(t.TempDir(), "fortunes")
+	if err := os.WriteFile(path, []byte("a\n%\nb\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	RootCmd.SetArgs([]string{"strfile", "-c", "", path})
+	if err := RootCmd.Execute(); !errors.Is(err, pkg.ErrInvalidDelimiter) {
+		t.Errorf("expected ErrInvalidDelimiter, got %v", err)
+	}
+	if pkg.FileExists(path + ".dat") {
+		t.Error("expected no index to be written")
+	}
+}
+
+// TestStrfileSourceDateEpochIsReproducible verifies that with SOURCE_DATE_EPOCH
+// set, indexes of the same contents are byte-identical whatever the
+// modification time of their source, shuffled or not.
+func TestStrfileSourceDateEpochIsReproducible(t *testing.T) {
+	saved := strFileCmdRequest
+	t.Cleanup(func() {
+		strFileCmdRequest = saved
+		for _, name := range []string{"silent", "order", "randomize"} {
+			strfileCmd.Flags().Lookup(name).Changed = false
+		}
+		RootCmd.SetArgs(nil)
+	})
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	build := func(modTime time.Time, flags ...string) []byte {
+		strFileCmdRequest = saved
+		dir := t.TempDir()
+		sourceFile, dataFile := filepath.Join(dir, "fortunes"), filepath.Join(dir, "fortunes.dat")
+		if err := os.WriteFile(sourceFile, []byte("b\n%\na\n%\nc\n%\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(sourceFile, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		RootCmd.SetArgs(append(append([]string{"strfile", "-s"}, flags...), sourceFile, dataFile))
+		if err := RootCmd.Execute(); err != nil {
+			t.Fatalf("strfile %q: %v", flags, err)
+		}
+		data, err := os.ReadFile(dataFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	for _, flags := range [][]string{nil, {"-o"}, {"-n"}} {
+		first := build(time.Unix(1000, 0), flags...)
+		if second := build(time.Unix(2000, 0), flags...); !bytes.Equal(first, second) {
+			t.Errorf("strfile %q: expected identical indexes for different source modification times", flags)
+		}
+	}
+}
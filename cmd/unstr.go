@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/vromero/gofortune/pkg"
+	"github.com/vromero/gofortune/pkg/strfile"
+)
+
+var unstrName = "unstr"
+var unstrShortDescription = "Print the strings of a fortune file in the order of its index"
+var unstrLongDescription = `unstr undoes the work of strfile. It prints out the strings contained in datafile in
+the order in which they are listed in its index, datafile.dat, to standard output or to outfile. Each string is
+followed by a line holding the delimiting character stored in the index. Indexes created with ordering or
+randomization therefore produce a sorted or shuffled copy of the original file.`
+
+var unstrCmd = &cobra.Command{
+	Use:   unstrName + " <datafile> [outfile]",
+	Short: unstrShortDescription,
+	Long:  unstrLongDescription,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		sourceFile, dataFile := unstrPaths(args[0])
+
+		var output io.Writer = os.Stdout
+		if len(args) > 1 {
+			outputFile, cerr := os.Create(args[1])
+			if cerr != nil {
+				return cerr
+			}
+			defer func() {
+				if cerr := outputFile.Close(); cerr != nil && err == nil {
+					err = fmt.Errorf("close %q: %w", args[1], cerr)
+				}
+			}()
+			output = outputFile
+		}
+		return strfile.Unstr(sourceFile, dataFile, output)
+	},
+}
+
+// unstrPaths resolves the fortune file and index named by datafile. The
+// argument may name the index itself, or the fortune file, whose index is
+// looked up as "datafile.dat" (the name fortune expects) and then as the
+// strfile default of replacing its extension with ".dat".
+func unstrPaths(datafile string) (sourceFile string, dataFile string) {
+	if filepath.Ext(datafile) == ".dat" {
+		return pkg.RemoveFileExtension(datafile), datafile
+	}
+	if pkg.FileExists(datafile + ".dat") {
+		return datafile, datafile + ".dat"
+	}
+	return datafile, pkg.RemoveFileExtension(datafile) + ".dat"
+}
+
+func init() {
+	RootCmd.AddCommand(unstrCmd)
+}
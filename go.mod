@@ -3,12 +3,14 @@ module github.com/vromero/gofortune
 go 1.25.0
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/patrickdappollonio/localized v0.0.0-20170307163927-f0888e3caa61
 	github.com/spf13/cobra v1.10.2
+	golang.org/x/sys v0.43.0
+	golang.org/x/text v0.40.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.10 // indirect
-	golang.org/x/sys v0.43.0 // indirect
 )
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,18 +12,22 @@ import (
 func main() {
 	processAliases()
 	if err := cmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		if !errors.Is(err, cmd.ErrReported) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		os.Exit(1)
 	}
 }
 
-// To maintain compatibility with the classic tools: fortune, strfile. GoFortune supports to have its
+// To maintain compatibility with the classic tools: fortune, strfile, unstr. GoFortune supports to have its
 // executable renamed or symlinked. If the appropriate executable names are found, the command line will
 // be altered to honor the original syntax.
 func processAliases() {
 	switch getExecutableName() {
 	case "strfile":
 		os.Args = append([]string{"gofortune", "strfile"}, os.Args[1:]...)
+	case "unstr":
+		os.Args = append([]string{"gofortune", "unstr"}, os.Args[1:]...)
 	}
 }
 
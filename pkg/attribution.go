@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxAttributionLines bounds how many lines an attribution trailer may wrap
+// over.
+const maxAttributionLines = 3
+
+var (
+	// attributionDash matches the line an attribution trailer starts with,
+	// such as "-- Mark Twain" or "— Ovid".
+	attributionDash = regexp.MustCompile(`^(?:--|—|―)\s*(\S.*)$`)
+	// attributionYear matches a year ending an attribution, such as ", 1863"
+	// or " (1897)".
+	attributionYear = regexp.MustCompile(`^(.*?)[\s,]*\(?\b(1[0-9]{3}|20[0-9]{2})\)?\.?$`)
+)
+
+// Attribution describes who an entry is attributed to, as written in its
+// trailer. Fields missing from the trailer are left empty, or zero for Year.
+type Attribution struct {
+	Author string
+	Source string
+	Year   int
+}
+
+// IsZero reports whether a holds no attribution.
+func (a Attribution) IsZero() bool {
+	return a == Attribution{}
+}
+
+// ParseAttribution parses the attribution trailer ending text, if any: a
+// last line starting with "--" or a dash, such as
+//
+//	-- Mark Twain, "Following the Equator" (1897)
+//
+// possibly wrapped over indented continuation lines. The author runs up to
+// the first comma and the source, unquoted, after it; a year may end either.
+func ParseAttribution(text string) (Attribution, bool) {
+	lines := strings.Split(strings.TrimRight(text, " \t\r\n"), "\n")
+	// The first line is never a trailer: the entry would hold nothing else.
+	for i := len(lines) - 1; i > 0 && i >= len(lines)-maxAttributionLines; i-- {
+		line := strings.TrimSpace(lines[i])
+		if match := attributionDash.FindStringSubmatch(line); match != nil {
+			parts := []string{match[1]}
+			for _, continuation := range lines[i+1:] {
+				parts = append(parts, strings.TrimSpace(continuation))
+			}
+			return parseAttributionBody(strings.Join(parts, " "))
+		}
+		// Only indented lines continue a trailer.
+		if line == "" || strings.TrimLeft(lines[i], " \t") == lines[i] {
+			break
+		}
+	}
+	return Attribution{}, false
+}
+
+// parseAttributionBody splits the text following the dash of a trailer.
+func parseAttributionBody(body string) (Attribution, bool) {
+	var attribution Attribution
+	if match := attributionYear.FindStringSubmatch(body); match != nil {
+		attribution.Year, _ = strconv.Atoi(match[2])
+		body = match[1]
+	}
+	author, source, _ := strings.Cut(body, ",")
+	attribution.Author = strings.TrimSpace(author)
+	attribution.Source = strings.Trim(strings.TrimSpace(source), `"“”'`)
+	return attribution, !attribution.IsZero()
+}
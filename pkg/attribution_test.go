@@ -0,0 +1,27 @@
+package pkg
+
+import "testing"
+
+func TestParseAttribution(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected Attribution
+		ok       bool
+	}{
+		{"Be yourself.\n\t\t-- Oscar Wilde", Attribution{Author: "Oscar Wilde"}, true},
+		{"Quote.\n-- Mark Twain, \"Following the Equator\" (1897)\n", Attribution{Author: "Mark Twain", Source: "Following the Equator", Year: 1897}, true},
+		{"Four score.\n    -- Abraham Lincoln, 1863", Attribution{Author: "Abraham Lincoln", Year: 1863}, true},
+		{"Long quote.\n\t-- Richard Bach, \"Illusions: The Adventures of a\n\t   Reluctant Messiah\"", Attribution{Author: "Richard Bach", Source: "Illusions: The Adventures of a Reluctant Messiah"}, true},
+		{"Quote.\r\n— Ovid, Metamorphoses\r\n", Attribution{Author: "Ovid", Source: "Metamorphoses"}, true},
+		{"No attribution here.", Attribution{}, false},
+		{"-- Only a trailer", Attribution{}, false},
+		{"A -- in the middle\nof the text", Attribution{}, false},
+		{"-- Dialogue\nreply\nend", Attribution{}, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseAttribution(test.text)
+		if ok != test.ok || got != test.expected {
+			t.Errorf("%q: expected %+v (%v), got %+v (%v)", test.text, test.expected, test.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package pkg
+
+import "errors"
+
+// WriteAtBuffer is an in-memory io.WriterAt that grows as needed, zero
+// filling any gap left by writes past its end, as a file would. It lets index
+// writers target memory instead of a file.
+type WriteAtBuffer struct {
+	buffer []byte
+}
+
+// WriteAt writes p at offset off of the buffer.
+func (b *WriteAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if end := off + int64(len(p)); end > int64(len(b.buffer)) {
+		b.buffer = append(b.buffer, make([]byte, end-int64(len(b.buffer)))...)
+	}
+	return copy(b.buffer[off:], p), nil
+}
+
+// Bytes returns the contents of the buffer.
+func (b *WriteAtBuffer) Bytes() []byte {
+	return b.buffer
+}
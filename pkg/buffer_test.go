@@ -0,0 +1,24 @@
+package pkg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteAtBuffer(t *testing.T) {
+	var buffer WriteAtBuffer
+	if _, err := buffer.WriteAt([]byte("cd"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buffer.WriteAt([]byte("ab"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buffer.WriteAt([]byte("f"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte("abcd\x00f")
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("expected %q, got %q", expected, buffer.Bytes())
+	}
+}
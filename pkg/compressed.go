@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// decompressedChunkSize is how much a compressed data file is decoded by at a
+// time.
+const decompressedChunkSize = 64 * 1024
+
+// decompressedWindowSize bounds how much of the decoded contents of a
+// compressed data file are kept in memory. Collections this small are decoded
+// once however they are read; past it, reading before what is kept decodes
+// the file again from the start.
+const decompressedWindowSize = 16 << 20
+
+// decompressors maps the suffixes of the compressed data files fortune reads
+// to a function returning a decoder of their contents.
+var decompressors = map[string]func(io.Reader) (io.ReadCloser, error){
+	".gz": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	".zst": func(r io.Reader) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	},
+}
+
+// DataFile is a fortune data file opened for random access to its contents.
+type DataFile interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// IsCompressedFileName reports whether name is a data file stored compressed,
+// with gzip (".gz") or zstd (".zst"). Indexes of such files list offsets into
+// their decompressed contents.
+func IsCompressedFileName(name string) bool {
+	_, ok := decompressors[filepath.Ext(name)]
+	return ok
+}
+
+// OpenDataFile opens the data file at path for random access to its
+// contents. Compressed files cannot be seeked into, so they are decoded on
+// demand from the start up to the furthest offset read: picking an entry near
+// the end costs decoding nearly the whole file, on every run. At most the last
+// decompressedWindowSize bytes decoded are kept in memory, plus what is read,
+// so large files do not cost their decoded size in memory; reading before them
+// decodes the file again from the start.
+func OpenDataFile(path string) (DataFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	decompress, ok := decompressors[filepath.Ext(path)]
+	if !ok {
+		return file, nil
+	}
+	decoder, err := decompress(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("decompress %q: %w", path, err)
+	}
+	return &decompressedFile{file: file, decompress: decompress, decoder: decoder, window: decompressedWindowSize}, nil
+}
+
+// OpenDataReader opens the data file at path for reading its contents in
+// order, decompressing them when the file is compressed.
+func OpenDataReader(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	decompress, ok := decompressors[filepath.Ext(path)]
+	if !ok {
+		return file, nil
+	}
+	decoder, err := decompress(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("decompress %q: %w", path, err)
+	}
+	return &decompressedReader{ReadCloser: decoder, file: file}, nil
+}
+
+// DataSize returns the size of the contents of the data file at path, which
+// for compressed files means decoding them whole; see IsStaleIndex.
+func DataSize(path string) (int64, error) {
+	if !IsCompressedFileName(path) {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return stat.Size(), nil
+	}
+	reader, err := OpenDataReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = reader.Close() }()
+	return io.Copy(io.Discard, reader)
+}
+
+// decompressedReader closes both the decoder and the file it reads from.
+type decompressedReader struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (r *decompressedReader) Close() error {
+	return errors.Join(r.ReadCloser.Close(), r.file.Close())
+}
+
+// decompressedFile gives random access to the contents of a compressed file,
+// keeping in data the last contents decoded, from offset start on.
+type decompressedFile struct {
+	file       *os.File
+	decompress func(io.Reader) (io.ReadCloser, error)
+	decoder    io.ReadCloser
+	window     int
+	mutex      sync.Mutex
+	start      int64
+	data       []byte
+	err        error // io.EOF once decoded whole
+}
+
+func (f *decompressedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("read at negative offset %d", off)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.decoder == nil {
+		return 0, f.err
+	}
+	if off < f.start {
+		if err := f.rewind(); err != nil {
+			return 0, err
+		}
+	}
+	f.fill(off, off+int64(len(p)))
+	if off >= f.start+int64(len(f.data)) {
+		return 0, f.err
+	}
+	n := copy(p, f.data[off-f.start:])
+	if n < len(p) {
+		return n, f.err
+	}
+	return n, nil
+}
+
+// fill decodes until size bytes are available or decoding stops, dropping
+// what was decoded before keep once more than twice the window is held.
+func (f *decompressedFile) fill(keep int64, size int64) {
+	chunk := make([]byte, decompressedChunkSize)
+	for f.start+int64(len(f.data)) < size && f.err == nil {
+		n, err := io.ReadFull(f.decoder, chunk)
+		f.data = append(f.data, chunk[:n]...)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		f.err = err
+
+		if len(f.data) > 2*f.window {
+			drop := min(int64(len(f.data)-f.window), keep-f.start)
+			if drop > 0 {
+				// Copied, so the dropped contents can be collected.
+				f.data = append([]byte(nil), f.data[drop:]...)
+				f.start += drop
+			}
+		}
+	}
+}
+
+// rewind starts decoding the file over from the start. Reads fail with the
+// error once rewinding failed.
+func (f *decompressedFile) rewind() error {
+	err := f.decoder.Close()
+	f.start, f.data, f.decoder = 0, nil, nil
+	if err == nil {
+		_, err = f.file.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		f.decoder, err = f.decompress(f.file)
+	}
+	if err != nil {
+		f.err = fmt.Errorf("decompress %q: %w", f.file.Name(), err)
+		return f.err
+	}
+	f.err = nil
+	return nil
+}
+
+func (f *decompressedFile) Close() error {
+	if f.decoder == nil {
+		return f.file.Close()
+	}
+	return errors.Join(f.decoder.Close(), f.file.Close())
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeCompressedFile writes content compressed after the suffix of name to
+// a temporary directory and returns its path.
+func writeCompressedFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	switch filepath.Ext(name) {
+	case ".gz":
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case ".zst":
+		writer, err := zstd.NewWriter(&buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		buffer.WriteString(content)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenDataFileCompressed(t *testing.T) {
+	long := strings.Repeat("a line long enough to span several decoded chunks\n", 5000)
+	content := "first\n%\n" + long + "%\nlast\n%\n"
+	for _, name := range []string{"fortunes", "fortunes.gz", "fortunes.zst"} {
+		t.Run(name, func(t *testing.T) {
+			path := writeCompressedFile(t, name, content)
+			if IsCompressedFileName(path) != (name != "fortunes") {
+				t.Errorf("unexpected IsCompressedFileName for %q", name)
+			}
+			file, err := OpenDataFile(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer func() { _ = file.Close() }()
+
+			// Read backwards so already decoded contents are read again.
+			last := int64(len(content) - len("last\n%\n"))
+			if got, err := ReadData(file, last, -1, "%"); err != nil || got != "last" {
+				t.Errorf("expected %q, got %q (err=%v)", "last", got, err)
+			}
+			if got, err := ReadData(file, 8, -1, "%"); err != nil || got != strings.TrimSuffix(long, "\n") {
+				t.Errorf("expected %d bytes, got %d (err=%v)", len(long)-1, len(got), err)
+			}
+			if got, err := ReadData(file, 0, 6, "%"); err != nil || got != "first" {
+				t.Errorf("expected %q, got %q (err=%v)", "first", got, err)
+			}
+			if _, err := ReadData(file, int64(len(content))+1, -1, "%"); err == nil {
+				t.Error("expected an error past the end of the contents")
+			}
+
+			size, err := DataSize(path)
+			if err != nil || size != int64(len(content)) {
+				t.Errorf("expected size %d, got %d (err=%v)", len(content), size, err)
+			}
+		})
+	}
+}
+
+// TestFingerprintCompressed verifies that compressed files are fingerprinted
+// by their decompressed contents, so recompressing them does not make their
+// index stale while changing the contents does.
+func TestFingerprintCompressed(t *testing.T) {
+	path := writeCompressedFile(t, "fortunes.gz", "one\n%\ntwo\n%\n")
+	fingerprint, err := FingerprintFromPath(path)
+	if err != nil {
+		t.Fatalf("fingerprint: %v", err)
+	}
+	if fingerprint.Size != uint64(len("one\n%\ntwo\n%\n")) {
+		t.Errorf("expected the decompressed size, got %d", fingerprint.Size)
+	}
+
+	recompressed := writeCompressedFile(t, "fortunes.zst", "one\n%\ntwo\n%\n")
+	if matches, err := fingerprint.MatchesPath(recompressed); err != nil || !matches {
+		t.Errorf("expected same contents to match (err=%v)", err)
+	}
+	changed := writeCompressedFile(t, "fortunes.gz", "one\n%\nthree\n%\n")
+	if matches, err := fingerprint.MatchesPath(changed); err != nil || matches {
+		t.Errorf("expected changed contents not to match (err=%v)", err)
+	}
+}
+
+// TestOpenDataFileBoundsMemory verifies that compressed files keep a bounded
+// window of their decoded contents, decoding them again from the start to
+// read before it.
+func TestOpenDataFileBoundsMemory(t *testing.T) {
+	var content strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&content, "fortune number %d\n%%\n", i)
+	}
+	path := writeCompressedFile(t, "fortunes.gz", content.String())
+	file, err := OpenDataFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	decompressed := file.(*decompressedFile)
+	decompressed.window = decompressedChunkSize
+
+	last := int64(content.Len() - len("fortune number 19999\n%\n"))
+	if got, err := ReadData(file, last, -1, "%"); err != nil || got != "fortune number 19999" {
+		t.Errorf("expected the last fortune, got %q (err=%v)", got, err)
+	}
+	if held := len(decompressed.data); held > 3*decompressedChunkSize {
+		t.Errorf("expected at most %d decoded bytes held, got %d", 3*decompressedChunkSize, held)
+	}
+	if got, err := ReadData(file, 0, -1, "%"); err != nil || got != "fortune number 0" {
+		t.Errorf("expected the first fortune, got %q (err=%v)", got, err)
+	}
+	if decompressed.start != 0 {
+		t.Errorf("expected decoding to start over, kept contents from %d", decompressed.start)
+	}
+}
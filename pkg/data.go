@@ -3,43 +3,87 @@ package pkg
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
-	"os"
+	"math"
 )
 
-var (
-	maxDataSize = 4096
-)
+// ErrOffsetPastEOF is returned when an index points past the end of the data
+// file it describes, usually because the file changed after being indexed.
+var ErrOffsetPastEOF = errors.New("offset points past the end of the data file")
 
-// Reads a whole fortune from the fortune base file
-func ReadData(inputFile *os.File, pos int64) (string, error) {
-	buffer := make([]byte, maxDataSize)
-	_, err := inputFile.ReadAt(buffer, pos)
-	if err != nil && err != io.EOF {
-		return "", err
+// initialDataBufferSize is the buffer ReadData starts scanning with; it grows
+// as needed so entries of any length are returned whole.
+const initialDataBufferSize = 4096
+
+// Reads a whole fortune from the fortune base file. The fortune ends at the
+// first line that holds only delimiter, as written by strfile, and never
+// extends past end. A negative end reads up to the end of the file.
+//
+// inputFile only needs random access, so the files OpenDataFile returns for
+// compressed data are read the same way as plain ones.
+func ReadData(inputFile io.ReaderAt, pos int64, end int64, delimiter string) (string, error) {
+	if pos > 0 {
+		// The byte before pos exists whenever pos is at most the file size.
+		if _, err := inputFile.ReadAt(make([]byte, 1), pos-1); err == io.EOF {
+			return "", fmt.Errorf("%w: offset %d", ErrOffsetPastEOF, pos)
+		} else if err != nil {
+			return "", err
+		}
+	}
+	if end < 0 {
+		end = math.MaxInt64
+	}
+	if end < pos {
+		return "", fmt.Errorf("entry at offset %d ends before it starts at %d", pos, end)
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(buffer))
-	scanner.Split(advanceAwareSplitter)
+	maxSize := int(min(end-pos, math.MaxInt32)) + 1
+	scanner := bufio.NewScanner(io.NewSectionReader(inputFile, pos, end-pos))
+	scanner.Buffer(make([]byte, min(initialDataBufferSize, maxSize)), maxSize)
+	scanner.Split(delimiterAwareSplitter([]byte(delimiter)))
 	scanner.Scan()
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
 
 	return string(RemoveCRLF(scanner.Bytes())), nil
 }
 
-// advanceAwareSplitter splits byte array in lines returning also how many bytes were read.
-// It differs from the default line splitter by the ability to return how many bytes were read.
-func advanceAwareSplitter(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
+// delimiterAwareSplitter returns a split function whose tokens are whole
+// fortunes: everything up to (and including the newline preceding) the next
+// line holding only delimiter, optionally followed by a carriage return. The
+// advance skips the delimiter line itself.
+func delimiterAwareSplitter(delimiter []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		for start := 0; start < len(data); {
+			end := bytes.IndexByte(data[start:], '\n')
+			if end < 0 {
+				// A final delimiter line may lack its newline.
+				if atEOF && isDelimiterLine(data[start:], delimiter) {
+					return len(data), data[0:start], nil
+				}
+				break
+			}
+			end += start
+			if isDelimiterLine(data[start:end], delimiter) {
+				return end + 1, data[0:start], nil
+			}
+			start = end + 1
+		}
+		// If we're at EOF, we have a final, non-terminated fortune. Return it.
+		if atEOF {
+			return len(data), data, nil
+		}
+		// Request more data.
 		return 0, nil, nil
 	}
-	if i := bytes.Index(data, []byte("\n%\n")); i >= 0 {
-		// We have a full newline-terminated line.
-		return i + 1, data[0 : i+1], nil
-	}
-	// If we're at EOF, we have a final, non-terminated line. Return it.
-	if atEOF {
-		return len(data), data, nil
-	}
-	// Request more data.
-	return 0, nil, nil
+}
+
+func isDelimiterLine(line []byte, delimiter []byte) bool {
+	return bytes.Equal(bytes.TrimSuffix(line, []byte("\r")), delimiter)
 }
@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDataFile(t *testing.T, content string) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fortunes")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+	return file
+}
+
+func TestReadDataStopsAtDelimiter(t *testing.T) {
+	file := writeDataFile(t, "one\n#\ntwo\n%\nstill two\n#\n")
+
+	tests := []struct {
+		pos      int64
+		expected string
+	}{
+		{0, "one"},
+		{6, "two\n%\nstill two"},
+	}
+	for _, tt := range tests {
+		got, err := ReadData(file, tt.pos, -1, "#")
+		if err != nil {
+			t.Fatalf("pos %d: unexpected error: %v", tt.pos, err)
+		}
+		if got != tt.expected {
+			t.Errorf("pos %d: expected %q, got %q", tt.pos, tt.expected, got)
+		}
+	}
+}
+
+func TestReadDataCRLFAndUnterminated(t *testing.T) {
+	file := writeDataFile(t, "one\r\n%\r\ntwo\n%")
+
+	got, err := ReadData(file, 0, -1, "%")
+	if err != nil || got != "one" {
+		t.Errorf("expected %q, got %q (err=%v)", "one", got, err)
+	}
+	got, err = ReadData(file, 8, -1, "%")
+	if err != nil || got != "two" {
+		t.Errorf("expected %q, got %q (err=%v)", "two", got, err)
+	}
+}
+
+// TestReadDataLongEntry is a regression guard for the former fixed 4096-byte
+// read buffer, which silently truncated longer entries.
+func TestReadDataLongEntry(t *testing.T) {
+	long := strings.Repeat("all work and no play makes jack a dull boy\n", 1000)
+	file := writeDataFile(t, long+"%\nshort\n%\n")
+
+	got, err := ReadData(file, 0, -1, "%")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != strings.TrimSuffix(long, "\n") {
+		t.Errorf("expected %d bytes, got %d", len(long)-1, len(got))
+	}
+
+	got, err = ReadData(file, int64(len(long)+2), int64(len(long)+10), "%")
+	if err != nil || got != "short" {
+		t.Errorf("expected %q, got %q (err=%v)", "short", got, err)
+	}
+}
+
+func TestReadDataEndBound(t *testing.T) {
+	file := writeDataFile(t, "one\ntwo\n")
+
+	got, err := ReadData(file, 0, 4, "%")
+	if err != nil || got != "one" {
+		t.Errorf("expected %q, got %q (err=%v)", "one", got, err)
+	}
+}
+
+func TestReadDataOffsetPastEOF(t *testing.T) {
+	file := writeDataFile(t, "one\n%\n")
+
+	if _, err := ReadData(file, 100, -1, "%"); !errors.Is(err, ErrOffsetPastEOF) {
+		t.Errorf("expected ErrOffsetPastEOF, got %v", err)
+	}
+}
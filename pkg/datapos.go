@@ -1,44 +1,40 @@
 package pkg
 
 import (
-	"encoding/binary"
-	"fmt"
-	"os"
-	"unsafe"
+	"errors"
+	"io"
 )
 
+// ErrOffsetOverflow is returned when an offset does not fit the offset width
+// of the index being written.
+var ErrOffsetOverflow = errors.New("offset does not fit the index offset width")
+
+// DataPos is an entry of an index offset table. Text and Length are not
+// stored in the table; strfile uses them while building the index.
 type DataPos struct {
-	OriginalOffset uint32
+	OriginalOffset uint64
 	Text           string
+	Length         uint32
 }
 
-func ReadDataPos(inputFile *os.File, tableSize int, position uint32) (DataPos, error) {
-	buffer := make([]byte, 4)
-	_, err := inputFile.ReadAt(buffer, int64(int64(tableSize)+int64(position)*4))
-	if err != nil {
-		return DataPos{}, err
-	}
-
-	return DataPos{
-		OriginalOffset: binary.BigEndian.Uint32(buffer),
-	}, nil
+// ReadDataPos reads the offset listed at position of the offset table that
+// starts tableSize bytes into inputFile, in the DefaultIndexFormat layout.
+func ReadDataPos(inputFile io.ReaderAt, tableSize int, position uint32) (DataPos, error) {
+	return DefaultIndexFormat.readDataPosAt(inputFile, int64(tableSize), position)
 }
 
 // WriteDataPos writes a single DataPos entry to outputFile at the offset
-// implied by position. Returns any write error so callers can surface a
-// failed write instead of silently producing a corrupt index file.
-func WriteDataPos(outputFile *os.File, tableSize int, position uint32, datapos DataPos) error {
-	buffer := make([]byte, 4)
-	binary.BigEndian.PutUint32(buffer, datapos.OriginalOffset)
-	if _, err := outputFile.WriteAt(buffer, int64(tableSize)+int64(unsafe.Sizeof(position))*int64(position)); err != nil {
-		return fmt.Errorf("write data pos at position %d: %w", position, err)
-	}
-	return nil
+// implied by position, in the DefaultIndexFormat layout. Returns
+// ErrOffsetOverflow for offsets that do not fit in 32 bits; see
+// IndexFormat.WriteDataPos.
+func WriteDataPos(outputFile io.WriterAt, tableSize int, position uint32, datapos DataPos) error {
+	return DefaultIndexFormat.writeDataPosAt(outputFile, int64(tableSize), position, datapos)
 }
 
-// WriteDataPosSlice writes every entry in dataposSlice in order. Returns the
-// first write error encountered, stopping the iteration.
-func WriteDataPosSlice(outputFile *os.File, tableSize int, dataposSlice []DataPos) error {
+// WriteDataPosSlice writes every entry in dataposSlice in order, in the
+// DefaultIndexFormat layout. Returns the first write error encountered,
+// stopping the iteration.
+func WriteDataPosSlice(outputFile io.WriterAt, tableSize int, dataposSlice []DataPos) error {
 	for i := range dataposSlice {
 		if err := WriteDataPos(outputFile, tableSize, uint32(i), dataposSlice[i]); err != nil {
 			return err
@@ -47,6 +43,9 @@ func WriteDataPosSlice(outputFile *os.File, tableSize int, dataposSlice []DataPo
 	return nil
 }
 
+// LessThanDataPos reports whether the entry i sorts before j: their whole
+// Text, after OrderingKey, is compared byte by byte, which for UTF-8 text is
+// code point order.
 func LessThanDataPos(i DataPos, j DataPos) bool {
-	return i.Text[0:1] < j.Text[0:1]
+	return OrderingKey(i.Text) < OrderingKey(j.Text)
 }
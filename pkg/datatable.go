@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
-	"unicode/utf8"
 	"unsafe"
 )
 
@@ -32,15 +32,58 @@ type DataTable struct {
 	Stuff           [3]uint8
 }
 
+// DelimiterString returns the delimiter separating the entries the table
+// indexes. A zeroed delimiter, as found in hand-built tables, is read as the
+// classic percent sign.
+func (table DataTable) DelimiterString() string {
+	if table.Delimiter == 0 {
+		return "%"
+	}
+	return string([]byte{table.Delimiter})
+}
+
+// flagNames names the header flags in bit order.
+var flagNames = []struct {
+	flag uint32
+	name string
+}{
+	{FlagRandom, "FlagRandom"},
+	{FlagOrdered, "FlagOrdered"},
+	{FlagRotated, "FlagRotated"},
+}
+
+// FlagNames returns the names of the flags set in the table header, followed
+// by any bits strfile does not define as a single hexadecimal value.
+func (table DataTable) FlagNames() []string {
+	names := make([]string, 0, len(flagNames))
+	unknown := table.Flags
+	for _, flag := range flagNames {
+		if table.Flags&flag.flag != 0 {
+			names = append(names, flag.name)
+		}
+		unknown &^= flag.flag
+	}
+	if unknown != 0 {
+		names = append(names, fmt.Sprintf("%#x", unknown))
+	}
+	return names
+}
+
+// CreateDataTable returns the header of a DefaultVersion index. The header
+// holds a single byte of delimiter; longer delimiters must also be recorded
+// in the IndexExtensions, see IndexDelimiter.
 func CreateDataTable(numberOfStrings uint32, longestLength uint32, shortestLength uint32, flags uint32, delimiter string) (posContents DataTable) {
-	delimiterValue, _ := utf8.DecodeRuneInString(delimiter)
+	var delimiterValue byte
+	if delimiter != "" {
+		delimiterValue = delimiter[0]
+	}
 	return DataTable{
 		Version:         DefaultVersion,
 		NumberOfStrings: numberOfStrings,
 		LongestLength:   longestLength,
 		ShortestLength:  shortestLength,
 		Flags:           flags,
-		Delimiter:       uint8(delimiterValue)}
+		Delimiter:       delimiterValue}
 }
 
 func LoadDataTableVersionFromPath(inputFilePath string) (DataTableVersion, error) {
@@ -52,7 +95,7 @@ func LoadDataTableVersionFromPath(inputFilePath string) (DataTableVersion, error
 	return LoadDataTableVersion(inputFile)
 }
 
-func LoadDataTableVersion(inputFile *os.File) (posContents DataTableVersion, err error) {
+func LoadDataTableVersion(inputFile io.Reader) (posContents DataTableVersion, err error) {
 	err = binary.Read(inputFile, binary.BigEndian, &posContents)
 	return posContents, err
 }
@@ -66,7 +109,7 @@ func LoadDataTableFromPath(inputFilePath string) (DataTable, error) {
 	return LoadDataTable(inputFile)
 }
 
-func LoadDataTable(inputFile *os.File) (posContents DataTable, err error) {
+func LoadDataTable(inputFile io.Reader) (posContents DataTable, err error) {
 	err = binary.Read(inputFile, binary.BigEndian, &posContents)
 	return posContents, err
 }
@@ -74,7 +117,7 @@ func LoadDataTable(inputFile *os.File) (posContents DataTable, err error) {
 // SaveDataTable writes the header posContents at offset 0 of outputFile.
 // Returns any encoding or write error so callers can surface a corrupt or
 // truncated index instead of silently continuing.
-func SaveDataTable(outputFile *os.File, posContents DataTable) error {
+func SaveDataTable(outputFile io.WriterAt, posContents DataTable) error {
 	buffer := new(bytes.Buffer)
 	if err := binary.Write(buffer, binary.BigEndian, posContents); err != nil {
 		return fmt.Errorf("encode data table: %w", err)
@@ -0,0 +1,13 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDataTableFlagNamesUnknownBits(t *testing.T) {
+	got := DataTable{Flags: FlagRandom | 0x30}.FlagNames()
+	if expected := []string{"FlagRandom", "0x30"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidDelimiter is returned for delimiters that cannot be told apart
+// reliably from the text of the entries they separate.
+var ErrInvalidDelimiter = errors.New("invalid delimiter")
+
+// MaxDelimiterSize bounds the size in bytes of a delimiter.
+const MaxDelimiterSize = 255
+
+// delimiterExtensionVersion is the version of the encoding of the
+// ExtensionDelimiter record: a version byte followed by the UTF-8 delimiter.
+const delimiterExtensionVersion = 1
+
+// ValidateDelimiter checks that delimiter can separate entries: it must be
+// non-empty valid UTF-8 of at most MaxDelimiterSize bytes, fit on a single
+// line and neither hold control characters nor start or end with white
+// space, which would make lines that merely look alike ambiguous.
+func ValidateDelimiter(delimiter string) error {
+	switch {
+	case delimiter == "":
+		return fmt.Errorf("%w: empty", ErrInvalidDelimiter)
+	case len(delimiter) > MaxDelimiterSize:
+		return fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidDelimiter, delimiter, MaxDelimiterSize)
+	case !utf8.ValidString(delimiter):
+		return fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidDelimiter, delimiter)
+	case strings.IndexFunc(delimiter, unicode.IsControl) >= 0:
+		return fmt.Errorf("%w: %q holds control characters such as line breaks", ErrInvalidDelimiter, delimiter)
+	case strings.TrimSpace(delimiter) != delimiter:
+		return fmt.Errorf("%w: %q starts or ends with white space", ErrInvalidDelimiter, delimiter)
+	}
+	return nil
+}
+
+// IndexDelimiter returns the delimiter separating the entries of an index:
+// the one recorded in its extensions, for delimiters the header cannot hold,
+// or else the one in its header.
+func IndexDelimiter(table DataTable, extensions IndexExtensions) string {
+	if extensions.Delimiter != "" {
+		return extensions.Delimiter
+	}
+	return table.DelimiterString()
+}
+
+// encodeDelimiter encodes delimiter as the payload of an ExtensionDelimiter
+// record.
+func encodeDelimiter(delimiter string) []byte {
+	return append([]byte{delimiterExtensionVersion}, delimiter...)
+}
+
+// decodeDelimiter decodes the payload of an ExtensionDelimiter record.
+func decodeDelimiter(payload []byte) (string, error) {
+	if len(payload) < 2 || payload[0] != delimiterExtensionVersion {
+		return "", fmt.Errorf("%w: delimiter: unsupported encoding", ErrCorruptExtension)
+	}
+	delimiter := string(payload[1:])
+	if err := ValidateDelimiter(delimiter); err != nil {
+		return "", fmt.Errorf("%w: delimiter: %v", ErrCorruptExtension, err)
+	}
+	return delimiter, nil
+}
@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateDelimiter(t *testing.T) {
+	for _, delimiter := range []string{"%", "#", "§", "※※", "-- 8< --", "%%"} {
+		if err := ValidateDelimiter(delimiter); err != nil {
+			t.Errorf("%q: unexpected error: %v", delimiter, err)
+		}
+	}
+	for _, delimiter := range []string{"", " %", "% ", "%\n%", "%\r", "\t", "\xff", strings.Repeat("%", MaxDelimiterSize+1)} {
+		if err := ValidateDelimiter(delimiter); !errors.Is(err, ErrInvalidDelimiter) {
+			t.Errorf("%q: expected ErrInvalidDelimiter, got %v", delimiter, err)
+		}
+	}
+}
+
+// TestIndexDelimiter verifies that delimiters recorded in the extensions
+// take precedence over the single byte the header holds.
+func TestIndexDelimiter(t *testing.T) {
+	table := CreateDataTable(0, 0, 0, 0, "§")
+	if table.Delimiter != "§"[0] {
+		t.Errorf("expected the first byte of the delimiter in the header, got %#x", table.Delimiter)
+	}
+	if got := IndexDelimiter(table, IndexExtensions{Delimiter: "§"}); got != "§" {
+		t.Errorf("expected %q, got %q", "§", got)
+	}
+	if got := IndexDelimiter(CreateDataTable(0, 0, 0, 0, "#"), IndexExtensions{}); got != "#" {
+		t.Errorf("expected %q, got %q", "#", got)
+	}
+}
@@ -0,0 +1,182 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ExtensionMagic marks the start of the extension block strfile appends to
+// an index after its offset table. Other fortune implementations read the
+// header and offsets by position and never look past the table, so the block
+// keeps indexes readable by them.
+const ExtensionMagic = "GFXT"
+
+// Extension record tags. Readers skip records with unknown tags.
+const (
+	ExtensionLengths     uint32 = 1 /* per-entry lengths */
+	ExtensionFingerprint uint32 = 2 /* source file fingerprint */
+	ExtensionOrdering    uint32 = 3 /* rules of an ordered index */
+	ExtensionSeed        uint32 = 4 /* seed of a reproducible random index */
+	ExtensionDelimiter   uint32 = 5 /* delimiters the header cannot hold */
+)
+
+// ErrCorruptExtension is returned when an index extension block cannot be
+// decoded.
+var ErrCorruptExtension = errors.New("corrupt index extension")
+
+// IndexExtensions holds the optional information strfile records about an
+// index beyond what the classic format can express. The zero value describes
+// an index without extensions.
+type IndexExtensions struct {
+	// Lengths holds the length in bytes of each entry as returned by
+	// ReadData, in the order the entries are listed by the offset table.
+	Lengths []uint32
+	// Fingerprint identifies the data file the index was built from.
+	Fingerprint *SourceFingerprint
+	// Ordering records how the entries of an ordered index were sorted.
+	Ordering *Ordering
+	// Seed is the seed a random index was shuffled with, when seeded.
+	Seed *int64
+	// Delimiter holds delimiters longer than the single byte the header
+	// has room for, such as "§" or "+++"; empty otherwise.
+	Delimiter string
+}
+
+// HasLengths reports whether the extension lists the length of every one of
+// the numberOfStrings entries of its index.
+func (extensions IndexExtensions) HasLengths(numberOfStrings uint32) bool {
+	return extensions.Lengths != nil && len(extensions.Lengths) == int(numberOfStrings)
+}
+
+// extensionOffset returns where the extension block of an index with header
+// table starts: right after its offset table, which lists one offset per
+// entry plus the end offset.
+func (format IndexFormat) extensionOffset(table DataTable) int64 {
+	format = format.orDefault()
+	return int64(DataTableSize) + (int64(table.NumberOfStrings)+1)*int64(format.OffsetSize)
+}
+
+// hasExtensions reports whether an extension block starts at offset of
+// inputFile.
+func hasExtensions(inputFile io.ReaderAt, offset int64) bool {
+	magic := make([]byte, len(ExtensionMagic))
+	if _, err := inputFile.ReadAt(magic, offset); err != nil {
+		return false
+	}
+	return string(magic) == ExtensionMagic
+}
+
+// SaveIndexExtensions writes the extension block of the index outputFile,
+// whose header is table, after its offset table.
+func SaveIndexExtensions(outputFile io.WriterAt, format IndexFormat, table DataTable, extensions IndexExtensions) error {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(ExtensionMagic)
+
+	if extensions.Lengths != nil {
+		writeExtensionRecord(buffer, ExtensionLengths, extensions.Lengths)
+	}
+	if extensions.Fingerprint != nil {
+		writeExtensionRecord(buffer, ExtensionFingerprint, extensions.Fingerprint)
+	}
+	if extensions.Ordering != nil {
+		// Encoding an Ordering cannot fail.
+		payload, _ := extensions.Ordering.MarshalBinary()
+		writeExtensionRecord(buffer, ExtensionOrdering, payload)
+	}
+	if extensions.Seed != nil {
+		writeExtensionRecord(buffer, ExtensionSeed, extensions.Seed)
+	}
+	if extensions.Delimiter != "" {
+		writeExtensionRecord(buffer, ExtensionDelimiter, encodeDelimiter(extensions.Delimiter))
+	}
+
+	if _, err := outputFile.WriteAt(buffer.Bytes(), format.extensionOffset(table)); err != nil {
+		return fmt.Errorf("write index extensions: %w", err)
+	}
+	return nil
+}
+
+func writeExtensionRecord(buffer *bytes.Buffer, tag uint32, payload any) {
+	record := new(bytes.Buffer)
+	// Writing fixed-size values to a bytes.Buffer cannot fail.
+	_ = binary.Write(record, binary.BigEndian, payload)
+	_ = binary.Write(buffer, binary.BigEndian, tag)
+	_ = binary.Write(buffer, binary.BigEndian, uint32(record.Len()))
+	buffer.Write(record.Bytes())
+}
+
+func LoadIndexExtensionsFromPath(inputFilePath string, format IndexFormat, table DataTable) (IndexExtensions, error) {
+	inputFile, err := os.Open(inputFilePath)
+	if err != nil {
+		return IndexExtensions{}, err
+	}
+	defer func() { _ = inputFile.Close() }()
+	stat, err := inputFile.Stat()
+	if err != nil {
+		return IndexExtensions{}, err
+	}
+	return LoadIndexExtensions(inputFile, stat.Size(), format, table)
+}
+
+// LoadIndexExtensions reads the extension block of the size bytes index
+// inputFile, whose header is table. Indexes without an extension block yield
+// the zero IndexExtensions.
+func LoadIndexExtensions(inputFile io.ReaderAt, size int64, format IndexFormat, table DataTable) (IndexExtensions, error) {
+	offset := format.extensionOffset(table)
+	if !hasExtensions(inputFile, offset) {
+		return IndexExtensions{}, nil
+	}
+
+	reader := io.NewSectionReader(inputFile, offset+int64(len(ExtensionMagic)), size-offset-int64(len(ExtensionMagic)))
+	var extensions IndexExtensions
+	for {
+		var record struct{ Tag, Size uint32 }
+		if err := binary.Read(reader, binary.BigEndian, &record); err == io.EOF {
+			return extensions, nil
+		} else if err != nil {
+			return IndexExtensions{}, fmt.Errorf("%w: %v", ErrCorruptExtension, err)
+		}
+		// Sizes are checked before allocating, so a corrupt one cannot
+		// have a huge buffer allocated for it.
+		if position, _ := reader.Seek(0, io.SeekCurrent); int64(record.Size) > reader.Size()-position {
+			return IndexExtensions{}, fmt.Errorf("%w: record %d of %d bytes runs past the end of the index", ErrCorruptExtension, record.Tag, record.Size)
+		}
+		payload := make([]byte, record.Size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return IndexExtensions{}, fmt.Errorf("%w: record %d: %v", ErrCorruptExtension, record.Tag, err)
+		}
+
+		switch record.Tag {
+		case ExtensionLengths:
+			extensions.Lengths = make([]uint32, len(payload)/4)
+			if err := binary.Read(bytes.NewReader(payload), binary.BigEndian, extensions.Lengths); err != nil {
+				return IndexExtensions{}, fmt.Errorf("%w: lengths: %v", ErrCorruptExtension, err)
+			}
+		case ExtensionFingerprint:
+			extensions.Fingerprint = new(SourceFingerprint)
+			if err := binary.Read(bytes.NewReader(payload), binary.BigEndian, extensions.Fingerprint); err != nil {
+				return IndexExtensions{}, fmt.Errorf("%w: fingerprint: %v", ErrCorruptExtension, err)
+			}
+		case ExtensionOrdering:
+			extensions.Ordering = new(Ordering)
+			if err := extensions.Ordering.UnmarshalBinary(payload); err != nil {
+				return IndexExtensions{}, fmt.Errorf("%w: ordering", err)
+			}
+		case ExtensionSeed:
+			extensions.Seed = new(int64)
+			if err := binary.Read(bytes.NewReader(payload), binary.BigEndian, extensions.Seed); err != nil {
+				return IndexExtensions{}, fmt.Errorf("%w: seed: %v", ErrCorruptExtension, err)
+			}
+		case ExtensionDelimiter:
+			delimiter, err := decodeDelimiter(payload)
+			if err != nil {
+				return IndexExtensions{}, err
+			}
+			extensions.Delimiter = delimiter
+		}
+	}
+}
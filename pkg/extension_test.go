@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndexExtensionsRoundTrip(t *testing.T) {
+	for _, format := range []IndexFormat{DefaultIndexFormat, LargeFileIndexFormat} {
+		t.Run(format.String(), func(t *testing.T) {
+			table := DataTable{Version: DefaultVersion, NumberOfStrings: 3, Delimiter: '%'}
+			file := writeIndex(t, binary.BigEndian, format.OffsetSize, table, []uint64{0, 12, 30, 41})
+			file = reopenForWrite(t, file)
+
+			extensions := IndexExtensions{
+				Lengths:     []uint32{10, 16, 9},
+				Fingerprint: &SourceFingerprint{Size: 41, ModTime: 1700000000, SHA256: [32]byte{1, 2, 3}},
+				Delimiter:   "※※",
+			}
+			if err := SaveIndexExtensions(file, format, table, extensions); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+
+			detected, _, err := DetectIndexFormatFromPath(file.Name())
+			if err != nil {
+				t.Fatalf("detect: %v", err)
+			}
+			if detected != format {
+				t.Errorf("expected %v, got %v", format, detected)
+			}
+			got, err := LoadIndexExtensionsFromPath(file.Name(), detected, table)
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if !reflect.DeepEqual(got, extensions) {
+				t.Errorf("expected %+v, got %+v", extensions, got)
+			}
+			if !got.HasLengths(table.NumberOfStrings) {
+				t.Error("expected lengths for every entry")
+			}
+		})
+	}
+}
+
+func TestLoadIndexExtensionsAbsent(t *testing.T) {
+	table := DataTable{Version: DefaultVersion, NumberOfStrings: 1, Delimiter: '%'}
+	file := writeIndex(t, binary.BigEndian, 4, table, []uint64{0, 12})
+
+	got, err := LoadIndexExtensionsFromPath(file.Name(), DefaultIndexFormat, table)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.HasLengths(table.NumberOfStrings) {
+		t.Errorf("expected no extensions, got %+v", got)
+	}
+}
+
+// TestLoadIndexExtensionsOversizedRecord verifies that a record claiming more
+// bytes than the index holds is reported as corrupt instead of allocated.
+func TestLoadIndexExtensionsOversizedRecord(t *testing.T) {
+	table := DataTable{Version: DefaultVersion, NumberOfStrings: 1, Delimiter: '%'}
+	var buffer bytes.Buffer
+	_ = binary.Write(&buffer, binary.BigEndian, table)
+	_ = binary.Write(&buffer, binary.BigEndian, []uint32{0, 6})
+	buffer.WriteString(ExtensionMagic)
+	_ = binary.Write(&buffer, binary.BigEndian, struct{ Tag, Size uint32 }{ExtensionLengths, math.MaxUint32})
+	buffer.Write(make([]byte, 4))
+
+	index := bytes.NewReader(buffer.Bytes())
+	_, err := LoadIndexExtensions(index, index.Size(), DefaultIndexFormat, table)
+	if !errors.Is(err, ErrCorruptExtension) || !strings.Contains(err.Error(), "past the end") {
+		t.Errorf("expected ErrCorruptExtension for the record size, got %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package pkg
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 func RemoveFileExtension(file string) string {
@@ -14,3 +17,39 @@ func FileExists(name string) bool {
 	_, err := os.Stat(name)
 	return !os.IsNotExist(err)
 }
+
+// ignoredSuffixes lists the suffixes of files found next to fortune files
+// that are never fortune files themselves, after fortune(6).
+var ignoredSuffixes = []string{
+	".dat", ".pos", ".u8", ".c", ".h", ".p", ".i", ".f", ".pas", ".ftn",
+	".ins.c", ".ins,pas", ".ins.ftn", ".sml", MetadataSuffix,
+}
+
+// binarySniffSize is how much of a file IsBinaryFile inspects.
+const binarySniffSize = 512
+
+// IsIgnoredFileName reports whether name, found while listing a fortune
+// directory, is never a fortune file: hidden files, indexes and the other
+// companions fortune(6) skips.
+func IsIgnoredFileName(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, suffix := range ignoredSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBinaryFile reports whether the start of inputFile holds a NUL byte,
+// which never appears in fortune text.
+func IsBinaryFile(inputFile io.ReaderAt) (bool, error) {
+	head := make([]byte, binarySniffSize)
+	n, err := inputFile.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return bytes.IndexByte(head[:n], 0) >= 0, nil
+}
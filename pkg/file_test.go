@@ -38,3 +38,18 @@ func TestFileDoestExists(t *testing.T) {
 		t.Error("expected file to not exist")
 	}
 }
+
+func TestIsIgnoredFileName(t *testing.T) {
+	tests := map[string]bool{
+		"fortunes":     false,
+		"fortunes.txt": false,
+		"fortunes.dat": true,
+		"fortunes.u8":  true,
+		".fortunes":    true,
+	}
+	for name, expected := range tests {
+		if got := IsIgnoredFileName(name); got != expected {
+			t.Errorf("%q: expected %v, got %v", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// SourceFingerprint identifies the contents of the data file an index was
+// built from, so readers can tell when the file changed afterwards.
+type SourceFingerprint struct {
+	Size    uint64
+	ModTime int64 // Unix time in nanoseconds
+	SHA256  [sha256.Size]byte
+}
+
+// FingerprintFromPath computes the fingerprint of the file at inputFilePath.
+// Compressed files are fingerprinted by their decompressed contents, which
+// is what their indexes describe, and the modification time of the file.
+func FingerprintFromPath(inputFilePath string) (SourceFingerprint, error) {
+	if IsCompressedFileName(inputFilePath) {
+		return compressedFingerprint(inputFilePath)
+	}
+	inputFile, err := os.Open(inputFilePath)
+	if err != nil {
+		return SourceFingerprint{}, err
+	}
+	defer func() { _ = inputFile.Close() }()
+	return Fingerprint(inputFile)
+}
+
+func compressedFingerprint(inputFilePath string) (SourceFingerprint, error) {
+	stat, err := os.Stat(inputFilePath)
+	if err != nil {
+		return SourceFingerprint{}, err
+	}
+	reader, err := OpenDataReader(inputFilePath)
+	if err != nil {
+		return SourceFingerprint{}, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return SourceFingerprint{}, err
+	}
+	fingerprint := SourceFingerprint{Size: uint64(size), ModTime: stat.ModTime().UnixNano()}
+	copy(fingerprint.SHA256[:], hash.Sum(nil))
+	return fingerprint, nil
+}
+
+// Fingerprint computes the fingerprint of inputFile, reading it whole.
+func Fingerprint(inputFile *os.File) (SourceFingerprint, error) {
+	stat, err := inputFile.Stat()
+	if err != nil {
+		return SourceFingerprint{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, io.NewSectionReader(inputFile, 0, stat.Size()))
+	if err != nil {
+		return SourceFingerprint{}, err
+	}
+
+	fingerprint := SourceFingerprint{Size: uint64(size), ModTime: stat.ModTime().UnixNano()}
+	copy(fingerprint.SHA256[:], hash.Sum(nil))
+	return fingerprint, nil
+}
+
+// MatchesPath reports whether the file at inputFilePath still has the
+// contents fingerprint was taken from. Files with the recorded size and
+// modification time are assumed unchanged; otherwise a differing size is
+// conclusive and the contents are hashed only when the size matches, so a
+// file that was merely touched or copied is not reported as changed. The
+// size of compressed files says nothing about their contents, so they are
+// decompressed and hashed whenever their modification time differs.
+func (fingerprint SourceFingerprint) MatchesPath(inputFilePath string) (bool, error) {
+	return fingerprint.MatchesPathSince(inputFilePath, time.Time{})
+}
+
+// MatchesPathSince is MatchesPath for a fingerprint read from an index last
+// modified at indexModTime, unless zero. Reproducible indexes record no
+// modification time, which would have every check hash the file; a file
+// with the recorded size that was not modified after such an index was
+// written is assumed unchanged instead, as make(1) would.
+func (fingerprint SourceFingerprint) MatchesPathSince(inputFilePath string, indexModTime time.Time) (bool, error) {
+	stat, err := os.Stat(inputFilePath)
+	if err != nil {
+		return false, err
+	}
+	if !IsCompressedFileName(inputFilePath) && uint64(stat.Size()) != fingerprint.Size {
+		return false, nil
+	}
+	if stat.ModTime().UnixNano() == fingerprint.ModTime {
+		return true, nil
+	}
+	if fingerprint.ModTime == 0 && !indexModTime.IsZero() && !stat.ModTime().After(indexModTime) {
+		return true, nil
+	}
+	current, err := FingerprintFromPath(inputFilePath)
+	if err != nil {
+		return false, err
+	}
+	return current.Size == fingerprint.Size && current.SHA256 == fingerprint.SHA256, nil
+}
+
+// IsStaleIndex reports whether the data file at inputFilePath changed after
+// the index read from index, with the given layout, header and extensions,
+// was built. Indexes recording a fingerprint of the file are checked against
+// it, see MatchesPathSince, taking the modification time of index when it is
+// a file; others, such as those written by other strfile implementations, are
+// only known to be stale when their end offset lies past the end of the file.
+// Learning the end of a compressed file means decompressing it whole, which
+// every run would pay for, so compressed files are not checked without a
+// fingerprint.
+func IsStaleIndex(index io.ReaderAt, format IndexFormat, table DataTable, extensions IndexExtensions, inputFilePath string) (bool, error) {
+	if extensions.Fingerprint != nil {
+		var indexModTime time.Time
+		if indexFile, ok := index.(interface{ Stat() (fs.FileInfo, error) }); ok {
+			if stat, err := indexFile.Stat(); err == nil {
+				indexModTime = stat.ModTime()
+			}
+		}
+		matches, err := extensions.Fingerprint.MatchesPathSince(inputFilePath, indexModTime)
+		return !matches, err
+	}
+	if IsCompressedFileName(inputFilePath) {
+		return false, nil
+	}
+
+	end, err := format.ReadDataPos(index, table.NumberOfStrings)
+	if err != nil {
+		// Some writers omit the end offset; nothing to compare then.
+		return false, nil
+	}
+	size, err := DataSize(inputFilePath)
+	if err != nil {
+		return false, err
+	}
+	return end.OriginalOffset > uint64(size), nil
+}
+
+// IsStaleIndexFromPath is IsStaleIndex for the index file at indexFilePath.
+func IsStaleIndexFromPath(indexFilePath string, inputFilePath string) (bool, error) {
+	indexFile, err := os.Open(indexFilePath)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = indexFile.Close() }()
+
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return false, err
+	}
+	format, table, err := DetectIndexFormat(indexFile, stat.Size())
+	if err != nil {
+		return false, err
+	}
+	extensions, err := LoadIndexExtensions(indexFile, stat.Size(), format, table)
+	if err != nil {
+		return false, err
+	}
+	return IsStaleIndex(indexFile, format, table, extensions, inputFilePath)
+}
@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFingerprintMatchesPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fortunes")
+	if err := os.WriteFile(path, []byte("one\n%\ntwo\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := FingerprintFromPath(path)
+	if err != nil {
+		t.Fatalf("fingerprint: %v", err)
+	}
+
+	// Touching the file keeps the contents, so it still matches.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := fingerprint.MatchesPath(path); err != nil || !matches {
+		t.Errorf("expected touched file to match, got %v (err=%v)", matches, err)
+	}
+
+	// Same size, different contents.
+	if err := os.WriteFile(path, []byte("one\n%\nTWO\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := fingerprint.MatchesPath(path); err != nil || matches {
+		t.Errorf("expected edited file not to match, got %v (err=%v)", matches, err)
+	}
+
+	// Different size.
+	if err := os.WriteFile(path, []byte("one\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := fingerprint.MatchesPath(path); err != nil || matches {
+		t.Errorf("expected truncated file not to match, got %v (err=%v)", matches, err)
+	}
+}
+
+// TestFingerprintMatchesPathSince verifies that a fingerprint without a
+// modification time, as reproducible indexes record, is trusted without
+// hashing for files not modified after the index, and checked otherwise.
+func TestFingerprintMatchesPathSince(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fortunes")
+	if err := os.WriteFile(path, []byte("one\n%\ntwo\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := FingerprintFromPath(path)
+	if err != nil {
+		t.Fatalf("fingerprint: %v", err)
+	}
+	fingerprint.ModTime = 0
+
+	// Same size, different contents, older than the index: not hashed.
+	indexModTime := time.Now()
+	if err := os.WriteFile(path, []byte("one\n%\nTWO\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	earlier := indexModTime.Add(-time.Hour)
+	if err := os.Chtimes(path, earlier, earlier); err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := fingerprint.MatchesPathSince(path, indexModTime); err != nil || !matches {
+		t.Errorf("expected file older than the index to match, got %v (err=%v)", matches, err)
+	}
+
+	// Modified after the index: hashed.
+	later := indexModTime.Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := fingerprint.MatchesPathSince(path, indexModTime); err != nil || matches {
+		t.Errorf("expected file edited after the index not to match, got %v (err=%v)", matches, err)
+	}
+}
+
+// TestIsStaleIndexWithoutFingerprint verifies that indexes recording no
+// fingerprint are stale when they end past their file, except for compressed
+// files, which are not decompressed to learn their end.
+func TestIsStaleIndexWithoutFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	indexFile, err := os.Create(filepath.Join(dir, "fortunes.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = indexFile.Close() }()
+	table := CreateDataTable(1, 1000, 1000, 0, "%")
+	if err := SaveDataTable(indexFile, table); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultIndexFormat.WriteDataPosSlice(indexFile, []DataPos{{OriginalOffset: 0}, {OriginalOffset: 1000}}); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := filepath.Join(dir, "fortunes")
+	if err := os.WriteFile(plain, []byte("one\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStaleIndex(indexFile, DefaultIndexFormat, table, IndexExtensions{}, plain); err != nil || !stale {
+		t.Errorf("expected an index ending past the file to be stale, got %v (err=%v)", stale, err)
+	}
+
+	// Not even valid gzip: decompressing it would fail.
+	compressed := filepath.Join(dir, "fortunes.gz")
+	if err := os.WriteFile(compressed, []byte("one\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStaleIndex(indexFile, DefaultIndexFormat, table, IndexExtensions{}, compressed); err != nil || stale {
+		t.Errorf("expected a compressed file not to be checked, got %v (err=%v)", stale, err)
+	}
+}
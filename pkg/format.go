@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	// LegacyVersion is the header version written by the original BSD
+	// strfile and by OpenBSD.
+	LegacyVersion = 1
+	// LargeFileVersion is the header version of indexes written by strfile
+	// with 64-bit offsets, for data files of 4 GiB or more. Other fortune
+	// implementations do not read it.
+	LargeFileVersion = 3
+)
+
+// ErrUnknownIndexFormat is returned when a file is not an index in any of
+// the layouts DetectIndexFormat recognises.
+var ErrUnknownIndexFormat = errors.New("unknown index format")
+
+// IndexFormat describes the on-disk layout of an index file. Every known
+// strfile variant shares the DataTable header; they differ in the byte order
+// the header and offsets are stored in and in the width of each offset.
+//
+//   - fortune-mod writes version 2 big-endian headers with 32-bit offsets.
+//   - The original BSD strfile wrote version 1 headers with the same layout.
+//   - BSD systems such as FreeBSD and OpenBSD follow a version 2 or version 1
+//     header with 64-bit big-endian offsets.
+//   - Some old builds wrote everything in the host (little-endian) order.
+//   - strfile writes version 3 (LargeFileVersion) big-endian headers with
+//     64-bit offsets when asked to index large data files.
+//
+// The zero IndexFormat is the fortune-mod layout.
+type IndexFormat struct {
+	ByteOrder  binary.ByteOrder
+	OffsetSize int
+}
+
+var (
+	// DefaultIndexFormat is the layout written by strfile and fortune-mod.
+	DefaultIndexFormat = IndexFormat{ByteOrder: binary.BigEndian, OffsetSize: 4}
+	// LargeFileIndexFormat is the layout of LargeFileVersion indexes.
+	LargeFileIndexFormat = IndexFormat{ByteOrder: binary.BigEndian, OffsetSize: 8}
+)
+
+func (format IndexFormat) orDefault() IndexFormat {
+	if format.ByteOrder == nil || format.OffsetSize == 0 {
+		return DefaultIndexFormat
+	}
+	return format
+}
+
+// String returns a short description of the layout, such as "big-endian,
+// 32-bit offsets".
+func (format IndexFormat) String() string {
+	format = format.orDefault()
+	order := "big-endian"
+	if format.ByteOrder == binary.LittleEndian {
+		order = "little-endian"
+	}
+	return fmt.Sprintf("%s, %d-bit offsets", order, format.OffsetSize*8)
+}
+
+// ReadDataPos reads the offset listed at position of the index inputFile.
+func (format IndexFormat) ReadDataPos(inputFile io.ReaderAt, position uint32) (DataPos, error) {
+	return format.readDataPosAt(inputFile, int64(DataTableSize), position)
+}
+
+// readDataPosAt is ReadDataPos for an offset table starting at tableOffset.
+func (format IndexFormat) readDataPosAt(inputFile io.ReaderAt, tableOffset int64, position uint32) (DataPos, error) {
+	format = format.orDefault()
+	buffer := make([]byte, format.OffsetSize)
+	if _, err := inputFile.ReadAt(buffer, tableOffset+int64(position)*int64(format.OffsetSize)); err != nil {
+		return DataPos{}, err
+	}
+
+	if format.OffsetSize == 4 {
+		return DataPos{OriginalOffset: uint64(format.ByteOrder.Uint32(buffer))}, nil
+	}
+	return DataPos{OriginalOffset: format.ByteOrder.Uint64(buffer)}, nil
+}
+
+// WriteDataPos writes a single DataPos entry to outputFile at the offset
+// implied by position. Returns ErrOffsetOverflow if the offset does not fit
+// the offset width of the format.
+func (format IndexFormat) WriteDataPos(outputFile io.WriterAt, position uint32, datapos DataPos) error {
+	return format.writeDataPosAt(outputFile, int64(DataTableSize), position, datapos)
+}
+
+// writeDataPosAt is WriteDataPos for an offset table starting at tableOffset.
+func (format IndexFormat) writeDataPosAt(outputFile io.WriterAt, tableOffset int64, position uint32, datapos DataPos) error {
+	format = format.orDefault()
+	buffer := make([]byte, format.OffsetSize)
+	if format.OffsetSize == 4 {
+		if datapos.OriginalOffset > math.MaxUint32 {
+			return fmt.Errorf("write data pos at position %d: %w", position, ErrOffsetOverflow)
+		}
+		format.ByteOrder.PutUint32(buffer, uint32(datapos.OriginalOffset))
+	} else {
+		format.ByteOrder.PutUint64(buffer, datapos.OriginalOffset)
+	}
+	if _, err := outputFile.WriteAt(buffer, tableOffset+int64(position)*int64(format.OffsetSize)); err != nil {
+		return fmt.Errorf("write data pos at position %d: %w", position, err)
+	}
+	return nil
+}
+
+// WriteDataPosSlice writes every entry in dataposSlice in order. Returns the
+// first write error encountered, stopping the iteration.
+func (format IndexFormat) WriteDataPosSlice(outputFile io.WriterAt, dataposSlice []DataPos) error {
+	for i := range dataposSlice {
+		if err := format.WriteDataPos(outputFile, uint32(i), dataposSlice[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDataEnd returns the offset at which the entry listed at position ends.
+// It is only known for tables that list entries in file order, where it is
+// the offset of the following entry; otherwise, or when the table has no
+// following entry, -1 is returned so readers stop at the next delimiter or at
+// the end of the file.
+func (format IndexFormat) ReadDataEnd(inputFile io.ReaderAt, table DataTable, position uint32) int64 {
+	if table.Flags&(FlagRandom|FlagOrdered) != 0 {
+		return -1
+	}
+	next, err := format.ReadDataPos(inputFile, position+1)
+	if err != nil {
+		return -1
+	}
+	return int64(next.OriginalOffset)
+}
+
+func DetectIndexFormatFromPath(inputFilePath string) (IndexFormat, DataTable, error) {
+	inputFile, err := os.Open(inputFilePath)
+	if err != nil {
+		return IndexFormat{}, DataTable{}, err
+	}
+	defer func() { _ = inputFile.Close() }()
+	stat, err := inputFile.Stat()
+	if err != nil {
+		return IndexFormat{}, DataTable{}, err
+	}
+	return DetectIndexFormat(inputFile, stat.Size())
+}
+
+// DetectIndexFormat recognises the layout of the size bytes index inputFile and returns
+// it together with its header, decoded in the detected byte order. The byte
+// order is the one yielding a known header version; the offset width is the
+// one whose offset table accounts for the size of the file.
+//
+// Returns ErrUnknownIndexFormat if no known layout matches.
+func DetectIndexFormat(inputFile io.ReaderAt, size int64) (IndexFormat, DataTable, error) {
+	header := make([]byte, DataTableSize)
+	if _, err := inputFile.ReadAt(header, 0); err != nil {
+		return IndexFormat{}, DataTable{}, fmt.Errorf("%w: read header: %v", ErrUnknownIndexFormat, err)
+	}
+
+	for _, byteOrder := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		var table DataTable
+		if err := binary.Read(bytes.NewReader(header), byteOrder, &table); err != nil {
+			return IndexFormat{}, DataTable{}, err
+		}
+		if table.Version != LegacyVersion && table.Version != DefaultVersion && table.Version != LargeFileVersion {
+			continue
+		}
+		offsetSize, ok := detectOffsetSize(inputFile, size, table.NumberOfStrings)
+		if ok && table.Version == LargeFileVersion && offsetSize != LargeFileIndexFormat.OffsetSize {
+			ok = false
+		}
+		if !ok {
+			return IndexFormat{}, DataTable{}, fmt.Errorf("%w: %d strings do not fit a %d bytes offset table",
+				ErrUnknownIndexFormat, table.NumberOfStrings, size-int64(DataTableSize))
+		}
+		return IndexFormat{ByteOrder: byteOrder, OffsetSize: offsetSize}, table, nil
+	}
+	return IndexFormat{}, DataTable{}, fmt.Errorf("%w: unsupported version", ErrUnknownIndexFormat)
+}
+
+// detectOffsetSize infers the width of the offsets in the table of the size
+// bytes index inputFile, which lists numberOfStrings entries. strfile writes
+// one offset per entry plus the end offset, though some writers omit the
+// latter; tables with the end offset are preferred since one 64-bit offset
+// has the size of two 32-bit ones. Tables followed by an extension block
+// match when the block starts right after them.
+func detectOffsetSize(inputFile io.ReaderAt, size int64, numberOfStrings uint32) (int, bool) {
+	tableSize := size - int64(DataTableSize)
+	for _, entries := range []int64{int64(numberOfStrings) + 1, int64(numberOfStrings)} {
+		for _, offsetSize := range []int{4, 8} {
+			if tableSize == entries*int64(offsetSize) {
+				return offsetSize, true
+			}
+		}
+	}
+	for _, offsetSize := range []int{4, 8} {
+		end := (int64(numberOfStrings) + 1) * int64(offsetSize)
+		if tableSize > end && hasExtensions(inputFile, int64(DataTableSize)+end) {
+			return offsetSize, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeIndex writes an index with the given header and offsets encoded in
+// byteOrder with offsetSize-byte offsets, returning the opened file.
+func writeIndex(t *testing.T, byteOrder binary.ByteOrder, offsetSize int, table DataTable, offsets []uint64) *os.File {
+	t.Helper()
+	buffer := new(bytes.Buffer)
+	if err := binary.Write(buffer, byteOrder, table); err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range offsets {
+		if offsetSize == 4 {
+			_ = binary.Write(buffer, byteOrder, uint32(offset))
+		} else {
+			_ = binary.Write(buffer, byteOrder, offset)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "fortunes.dat")
+	if err := os.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+	return file
+}
+
+// reopenForWrite reopens file for reading and writing.
+func reopenForWrite(t *testing.T, file *os.File) *os.File {
+	t.Helper()
+	reopened, err := os.OpenFile(file.Name(), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = reopened.Close() })
+	return reopened
+}
+
+func TestDetectIndexFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		byteOrder  binary.ByteOrder
+		offsetSize int
+		version    uint32
+		offsets    []uint64
+	}{
+		{"fortune-mod", binary.BigEndian, 4, DefaultVersion, []uint64{0, 12, 30, 41}},
+		{"legacy", binary.BigEndian, 4, LegacyVersion, []uint64{0, 12, 30, 41}},
+		{"bsd 64-bit", binary.BigEndian, 8, DefaultVersion, []uint64{0, 12, 30, 41}},
+		{"bsd legacy 64-bit", binary.BigEndian, 8, LegacyVersion, []uint64{0, 12, 30, 41}},
+		{"little-endian", binary.LittleEndian, 4, DefaultVersion, []uint64{0, 12, 30, 41}},
+		{"without end offset", binary.BigEndian, 8, DefaultVersion, []uint64{0, 12, 30}},
+		{"large file", binary.BigEndian, 8, LargeFileVersion, []uint64{0, 12, 1 << 33, 1<<33 + 41}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := DataTable{Version: tt.version, NumberOfStrings: 3, LongestLength: 17, ShortestLength: 9, Delimiter: '%'}
+			file := writeIndex(t, tt.byteOrder, tt.offsetSize, table, tt.offsets)
+
+			format, got, err := DetectIndexFormatFromPath(file.Name())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if format.ByteOrder != tt.byteOrder || format.OffsetSize != tt.offsetSize {
+				t.Errorf("expected %v, got %v", IndexFormat{tt.byteOrder, tt.offsetSize}, format)
+			}
+			if got != table {
+				t.Errorf("expected header %+v, got %+v", table, got)
+			}
+			for i, offset := range tt.offsets {
+				dataPos, err := format.ReadDataPos(file, uint32(i))
+				if err != nil {
+					t.Fatalf("read offset %d: %v", i, err)
+				}
+				if dataPos.OriginalOffset != offset {
+					t.Errorf("offset %d: expected %d, got %d", i, offset, dataPos.OriginalOffset)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectIndexFormatRejectsUnknown(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   DataTable
+		offsets []uint64
+	}{
+		{"unknown version", DataTable{Version: 7, NumberOfStrings: 1}, []uint64{0, 10}},
+		{"truncated table", DataTable{Version: DefaultVersion, NumberOfStrings: 5}, []uint64{0, 10}},
+		{"large file with 32-bit offsets", DataTable{Version: LargeFileVersion, NumberOfStrings: 1}, []uint64{0, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeIndex(t, binary.BigEndian, 4, tt.table, tt.offsets)
+			if _, _, err := DetectIndexFormatFromPath(file.Name()); !errors.Is(err, ErrUnknownIndexFormat) {
+				t.Errorf("expected ErrUnknownIndexFormat, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteDataPosOverflow(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "fortunes.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	large := DataPos{OriginalOffset: 1 << 32}
+	if err := DefaultIndexFormat.WriteDataPos(file, 0, large); !errors.Is(err, ErrOffsetOverflow) {
+		t.Errorf("expected ErrOffsetOverflow, got %v", err)
+	}
+	if err := WriteDataPos(file, int(DataTableSize), 0, large); !errors.Is(err, ErrOffsetOverflow) {
+		t.Errorf("expected ErrOffsetOverflow from WriteDataPos, got %v", err)
+	}
+	if err := LargeFileIndexFormat.WriteDataPos(file, 0, large); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := LargeFileIndexFormat.ReadDataPos(file, 0)
+	if err != nil || got.OriginalOffset != large.OriginalOffset {
+		t.Errorf("expected %d, got %d (err=%v)", large.OriginalOffset, got.OriginalOffset, err)
+	}
+}
+
+// TestDataPosFunctions verifies that the package-level offset table
+// functions encode as DefaultIndexFormat does.
+func TestDataPosFunctions(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "fortunes.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	entries := []DataPos{{OriginalOffset: 0}, {OriginalOffset: 12}, {OriginalOffset: 40}}
+	if err := WriteDataPosSlice(file, int(DataTableSize), entries); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for i := range entries {
+		got, err := DefaultIndexFormat.ReadDataPos(file, uint32(i))
+		if err != nil || got.OriginalOffset != entries[i].OriginalOffset {
+			t.Errorf("entry %d: expected %d, got %d (err=%v)", i, entries[i].OriginalOffset, got.OriginalOffset, err)
+		}
+		if got, err := ReadDataPos(file, int(DataTableSize), uint32(i)); err != nil || got.OriginalOffset != entries[i].OriginalOffset {
+			t.Errorf("entry %d: expected %d from ReadDataPos, got %d (err=%v)", i, entries[i].OriginalOffset, got.OriginalOffset, err)
+		}
+	}
+}
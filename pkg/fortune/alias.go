@@ -0,0 +1,116 @@
+package fortune
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/rand/v2"
+)
+
+// leafTable is a Vose alias table over the leaves of a tree, picking each
+// leaf with a probability proportional to its exact weight in constant time.
+//
+// The weights, fractions computed from the number of fortunes of the files
+// and the N% weights of the paths, are brought to integers over their common
+// denominator when the table is built, so picks follow them exactly: every
+// leaf with a positive weight can be picked, in its exact proportion, and no
+// pick can fall between two leaves. Leaves whose weight is not positive are
+// never picked.
+type leafTable struct {
+	leaves []FileSystemNodeDescriptor
+	// A pick draws a column uniformly, then a number below total: below
+	// threshold, the column's own leaf is picked, otherwise its alias.
+	threshold []*big.Int
+	alias     []int
+	total     *big.Int
+}
+
+// newLeafTable builds the leafTable of the leaves under node.
+func newLeafTable(node FileSystemNodeDescriptor) *leafTable {
+	table := &leafTable{total: new(big.Int)}
+	var weights []*big.Rat
+	walkLeaves(node, func(leaf FileSystemNodeDescriptor) {
+		if weight := leaf.exactWeight(); weight.Sign() > 0 {
+			table.leaves = append(table.leaves, leaf)
+			weights = append(weights, weight)
+		}
+	})
+	if len(table.leaves) == 0 {
+		return table
+	}
+
+	denominator := big.NewInt(1)
+	for _, weight := range weights {
+		gcd := new(big.Int).GCD(nil, nil, denominator, weight.Denom())
+		denominator.Mul(denominator, new(big.Int).Quo(weight.Denom(), gcd))
+	}
+	// Columns hold n times the integer weights, so that they add up to n
+	// columns of total each.
+	n := big.NewInt(int64(len(table.leaves)))
+	scaled := make([]*big.Int, len(weights))
+	for i, weight := range weights {
+		scaled[i] = new(big.Int).Quo(denominator, weight.Denom())
+		scaled[i].Mul(scaled[i], weight.Num())
+		table.total.Add(table.total, scaled[i])
+		scaled[i].Mul(scaled[i], n)
+	}
+
+	table.threshold = make([]*big.Int, len(scaled))
+	table.alias = make([]int, len(scaled))
+	var small, large []int
+	for i := range scaled {
+		if scaled[i].Cmp(table.total) < 0 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		table.threshold[s], table.alias[s] = scaled[s], l
+		// The large leaf fills the rest of the column of the small one.
+		scaled[l].Sub(scaled[l], new(big.Int).Sub(table.total, scaled[s]))
+		if scaled[l].Cmp(table.total) < 0 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// With exact arithmetic, what remains fills its own column.
+	for _, i := range append(small, large...) {
+		table.threshold[i], table.alias[i] = table.total, i
+	}
+	return table
+}
+
+// pick returns a leaf of table drawn from random. table must not be empty.
+func (table *leafTable) pick(random *rand.Rand) FileSystemNodeDescriptor {
+	column := random.IntN(len(table.leaves))
+	if randomBelow(random, table.total).Cmp(table.threshold[column]) < 0 {
+		return table.leaves[column]
+	}
+	return table.leaves[table.alias[column]]
+}
+
+// randomBelow returns a number drawn uniformly from [0, n) from random. n
+// must be positive.
+func randomBelow(random *rand.Rand, n *big.Int) *big.Int {
+	if n.IsUint64() {
+		return new(big.Int).SetUint64(random.Uint64N(n.Uint64()))
+	}
+	// Draw numbers as long as n until one is below it, which takes less than
+	// two draws on average.
+	buffer := make([]byte, (n.BitLen()+7)/8)
+	excess := uint(len(buffer)*8 - n.BitLen())
+	var word [8]byte
+	number := new(big.Int)
+	for {
+		for i := 0; i < len(buffer); i += len(word) {
+			binary.BigEndian.PutUint64(word[:], random.Uint64())
+			copy(buffer[i:], word[:])
+		}
+		buffer[0] &= 0xff >> excess
+		if number.SetBytes(buffer).Cmp(n) < 0 {
+			return number
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package fortune
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// heldProbabilities returns the exact probability the columns of table give
+// each of its leaves, by path.
+func heldProbabilities(table *leafTable) map[string]*big.Rat {
+	held := make(map[string]*big.Rat)
+	add := func(path string, amount *big.Int) {
+		if held[path] == nil {
+			held[path] = new(big.Rat)
+		}
+		held[path].Add(held[path], new(big.Rat).SetFrac(amount, new(big.Int).Mul(table.total, big.NewInt(int64(len(table.leaves))))))
+	}
+	for column := range table.leaves {
+		add(table.leaves[column].Path, table.threshold[column])
+		add(table.leaves[table.alias[column]].Path, new(big.Int).Sub(table.total, table.threshold[column]))
+	}
+	return held
+}
+
+// TestLeafTableProbabilities verifies that the alias table gives each leaf
+// exactly its share of the total Percent, never picks a leaf without one, and
+// draws picks accordingly.
+func TestLeafTableProbabilities(t *testing.T) {
+	percents := []float32{0.001, 2, 0, 30.5, 67.499}
+	root := FileSystemNodeDescriptor{Children: []FileSystemNodeDescriptor{{Children: []FileSystemNodeDescriptor{{}, {}}}, {}, {}, {}}}
+	root.Children[0].Children[0].Percent, root.Children[0].Children[1].Percent = percents[0], percents[1]
+	for i := 1; i < 4; i++ {
+		root.Children[i].Percent = percents[i+1]
+	}
+	for i, leaf := range []*FileSystemNodeDescriptor{&root.Children[0].Children[0], &root.Children[0].Children[1], &root.Children[1], &root.Children[2], &root.Children[3]} {
+		leaf.Path = string(rune('a' + i))
+	}
+
+	table := newLeafTable(root)
+	if len(table.leaves) != 4 {
+		t.Fatalf("expected the 4 leaves with a Percent, got %d", len(table.leaves))
+	}
+	total := new(big.Rat)
+	for _, percent := range percents {
+		total.Add(total, exactPercent(percent))
+	}
+	held := heldProbabilities(table)
+	for i, path := range []string{"a", "b", "c", "d", "e"} {
+		want := new(big.Rat).Quo(exactPercent(percents[i]), total)
+		if got := held[path]; (got == nil && want.Sign() != 0) || (got != nil && got.Cmp(want) != 0) {
+			t.Errorf("leaf %s: expected probability %v, got %v", path, want, got)
+		}
+	}
+
+	random := NewSeededRand(7)
+	counts := make(map[string]int)
+	const picks = 200000
+	for i := 0; i < picks; i++ {
+		counts[table.pick(random).Path]++
+	}
+	if counts["c"] != 0 {
+		t.Errorf("a leaf without Percent was picked %d times", counts["c"])
+	}
+	if share := float64(counts["e"]) / picks; math.Abs(share-0.67499) > 0.01 {
+		t.Errorf("expected leaf e about 67.5%% of the time, got %.2f%%", share*100)
+	}
+}
+
+// TestLeafTableExactWeights verifies that the odds SetProbabilities computes
+// are followed exactly, including shares no float holds, such as a third.
+func TestLeafTableExactWeights(t *testing.T) {
+	newRoot := func() FileSystemNodeDescriptor {
+		return FileSystemNodeDescriptor{
+			NumFiles: 3,
+			Children: []FileSystemNodeDescriptor{
+				{Path: "a", NumEntries: 1, NumFiles: 1},
+				{Path: "b", NumEntries: 7, NumFiles: 1},
+				{Path: "c", NumEntries: 1 << 40, NumFiles: 1},
+			},
+		}
+	}
+	root := newRoot()
+	SetProbabilitiesWithStrategy(&root, SelectFilesEqually)
+	for path, got := range heldProbabilities(root.leafTable) {
+		if got.Cmp(big.NewRat(1, 3)) != 0 {
+			t.Errorf("leaf %s: expected probability 1/3, got %v", path, got)
+		}
+	}
+
+	root = newRoot()
+	SetProbabilitiesWithStrategy(&root, SelectFortunesEqually)
+	held := heldProbabilities(root.leafTable)
+	if want := big.NewRat(7, 1<<40+8); held["b"].Cmp(want) != 0 {
+		t.Errorf("expected probability %v for b, got %v", want, held["b"])
+	}
+}
+
+// TestLeafTableWeightedPaths verifies that with the default strategy the
+// table gives every path with an N% weight exactly N%, shared by its files by
+// their number of fortunes, and the other paths exactly what is left.
+func TestLeafTableWeightedPaths(t *testing.T) {
+	root := FileSystemNodeDescriptor{
+		Percent: 100,
+		Children: []FileSystemNodeDescriptor{
+			{Path: "a", Percent: 30, NumEntries: 12, Children: []FileSystemNodeDescriptor{{Path: "a1", NumEntries: 3}, {Path: "a2", NumEntries: 9}}},
+			{Path: "b", Percent: 20, NumEntries: 5},
+			{Path: "c", NumEntries: 40},
+		},
+	}
+	SetProbabilities(&root, false)
+
+	held := heldProbabilities(root.leafTable)
+	for path, want := range map[string]*big.Rat{"a1": big.NewRat(3, 40), "a2": big.NewRat(9, 40), "b": big.NewRat(1, 5), "c": big.NewRat(1, 2)} {
+		if held[path].Cmp(want) != 0 {
+			t.Errorf("leaf %s: expected probability %v, got %v", path, want, held[path])
+		}
+	}
+}
+
+// TestGetRandomLeafNodeNoProbability verifies that a tree whose leaves all
+// have no chance of being chosen fails with ErrNoProbability.
+func TestGetRandomLeafNodeNoProbability(t *testing.T) {
+	root := FileSystemNodeDescriptor{Children: []FileSystemNodeDescriptor{{Path: "/a"}, {Path: "/b"}}}
+	if _, err := GetRandomLeafNode(root); !errors.Is(err, ErrNoProbability) {
+		t.Errorf("expected ErrNoProbability, got %v", err)
+	}
+}
+
+func TestRandomBelow(t *testing.T) {
+	random := NewSeededRand(1)
+	n := new(big.Int).Lsh(big.NewInt(3), 70)
+	var high bool
+	for i := 0; i < 1000; i++ {
+		number := randomBelow(random, n)
+		if number.Sign() < 0 || number.Cmp(n) >= 0 {
+			t.Fatalf("expected a number below %v, got %v", n, number)
+		}
+		high = high || number.BitLen() == n.BitLen()
+	}
+	if !high {
+		t.Error("expected numbers as long as the bound to be drawn")
+	}
+}
@@ -0,0 +1,120 @@
+package fortune
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// ErrNoFortuneMatchesAttribution is returned by FilterByAttribution when no
+// loaded fortune has a matching attribution.
+var ErrNoFortuneMatchesAttribution = errors.New("no fortune matches the attribution filter")
+
+// AttributionFilter selects fortunes by the attribution trailer ending them.
+// Nil expressions match any attribution, including none.
+type AttributionFilter struct {
+	Author *regexp.Regexp
+	Source *regexp.Regexp
+}
+
+// NewAttributionFilter compiles the author and source expressions of a
+// filter; empty expressions are left nil. With ignoreCase they match
+// regardless of case, like the -m expressions of GetFortunesMatching.
+func NewAttributionFilter(author string, source string, ignoreCase bool) (AttributionFilter, error) {
+	var filter AttributionFilter
+	var err error
+	if filter.Author, err = compileAttributionExpression(author, ignoreCase); err != nil {
+		return AttributionFilter{}, err
+	}
+	if filter.Source, err = compileAttributionExpression(source, ignoreCase); err != nil {
+		return AttributionFilter{}, err
+	}
+	return filter, nil
+}
+
+func compileAttributionExpression(expression string, ignoreCase bool) (*regexp.Regexp, error) {
+	if expression == "" {
+		return nil, nil
+	}
+	if ignoreCase {
+		expression = "(?i)" + expression
+	}
+	return regexp.Compile(expression)
+}
+
+// IsZero reports whether filter selects every fortune.
+func (filter AttributionFilter) IsZero() bool {
+	return filter.Author == nil && filter.Source == nil
+}
+
+// Matches reports whether attribution passes filter.
+func (filter AttributionFilter) Matches(attribution pkg.Attribution) bool {
+	if filter.Author != nil && (attribution.Author == "" || !filter.Author.MatchString(attribution.Author)) {
+		return false
+	}
+	if filter.Source != nil && (attribution.Source == "" || !filter.Source.MatchString(attribution.Source)) {
+		return false
+	}
+	return true
+}
+
+// FilterByAttribution returns a copy of rootNode restricted to the fortunes
+// whose attribution passes filter, for GetRandomFortune,
+// GetLengthFilteredRandomFortune and GetFortunesMatching to choose from.
+// Leaves keep their weight relative to one another scaled by the share of
+// their entries that remain, so call it after SetProbabilities.
+//
+// Attributions are read from the metadata index strfile --metadata writes
+// next to an index file. Leaves without an up to date one, such as those
+// indexed in memory, have their entries read to parse it instead.
+func FilterByAttribution(rootNode FileSystemNodeDescriptor, filter AttributionFilter) (FileSystemNodeDescriptor, error) {
+	filtered, ok, err := filterEntries(rootNode, func(leaf FileSystemNodeDescriptor) ([]uint32, error) {
+		attributions, err := leafAttributions(leaf)
+		if err != nil {
+			return nil, err
+		}
+		var positions []uint32
+		for i := 0; i < int(leaf.NumEntries); i++ {
+			position := leaf.entryPosition(i)
+			if filter.Matches(attributions[position]) {
+				positions = append(positions, position)
+			}
+		}
+		return positions, nil
+	})
+	if err != nil {
+		return FileSystemNodeDescriptor{}, err
+	}
+	if !ok {
+		return FileSystemNodeDescriptor{}, ErrNoFortuneMatchesAttribution
+	}
+	return filtered, nil
+}
+
+// leafAttributions returns the attribution of every entry of the leaf node,
+// in index order.
+func leafAttributions(node FileSystemNodeDescriptor) ([]pkg.Attribution, error) {
+	if node.IndexPath != "" {
+		metadata, err := pkg.LoadMetadataIndexFromPath(pkg.MetadataIndexPath(node.IndexPath))
+		if err == nil && metadata.Matches(node.Table, node.Extensions) {
+			return metadata.Attributions, nil
+		}
+	}
+
+	reader, err := openLeaf(node)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	attributions := make([]pkg.Attribution, node.Table.NumberOfStrings)
+	for position := range attributions {
+		cookie, err := reader.entry(uint32(position))
+		if err != nil {
+			return nil, err
+		}
+		attributions[position] = cookie.Attribution
+	}
+	return attributions, nil
+}
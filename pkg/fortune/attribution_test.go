@@ -0,0 +1,101 @@
+package fortune
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vromero/gofortune/pkg"
+	"github.com/vromero/gofortune/pkg/strfile"
+)
+
+// writeAttributedDir writes a directory holding a collection indexed with a
+// metadata index and one indexed only in memory.
+func writeAttributedDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	indexed := "Zeal.\n\t-- Oscar Wilde\n%\nNo trailer\n%\nAll of it.\n\t-- Mark Twain, Following the Equator\n%\n"
+	if err := os.WriteFile(filepath.Join(dir, "indexed"), []byte(indexed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strfile.StrFile(filepath.Join(dir, "indexed"), filepath.Join(dir, "indexed.dat"), strfile.Options{Randomize: true, Metadata: true}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+	fresh := "Truth.\n\t-- Mark Twain, Pudd'nhead Wilson\n%\nAnonymous\n%\n"
+	if err := os.WriteFile(filepath.Join(dir, "fresh"), []byte(fresh), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFilterByAttribution(t *testing.T) {
+	dir := writeAttributedDir(t)
+	if !pkg.FileExists(filepath.Join(dir, "indexed.meta")) {
+		t.Fatal("expected strfile to write a metadata index")
+	}
+	root, err := LoadPaths([]ProbabilityPath{{Path: dir}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	SetProbabilities(&root, false)
+
+	filter, err := NewAttributionFilter("twain", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := FilterByAttribution(root, filter)
+	if err != nil {
+		t.Fatalf("filter: %v", err)
+	}
+	if filtered.NumEntries != 2 || filtered.NumFiles != 2 {
+		t.Errorf("expected 2 entries in 2 files, got %d in %d", filtered.NumEntries, filtered.NumFiles)
+	}
+	for i := 0; i < 20; i++ {
+		cookie, err := GetRandomFortune(filtered)
+		if err != nil {
+			t.Fatalf("get random fortune: %v", err)
+		}
+		if cookie.Author != "Mark Twain" {
+			t.Fatalf("expected a Mark Twain fortune, got %+v", cookie)
+		}
+	}
+
+	filter, err = NewAttributionFilter("", "^Pudd", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err = FilterByAttribution(root, filter)
+	if err != nil {
+		t.Fatalf("filter: %v", err)
+	}
+	dataCh, errCh := GetFortunesMatching(filtered, ".", false)
+	var sources []string
+	for dataCh != nil || errCh != nil {
+		select {
+		case cookie, ok := <-dataCh:
+			if !ok {
+				dataCh = nil
+				continue
+			}
+			sources = append(sources, cookie.Source)
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if len(sources) != 1 || sources[0] != "Pudd'nhead Wilson" {
+		t.Errorf("expected the single Pudd'nhead Wilson fortune, got %q", sources)
+	}
+
+	filter, err = NewAttributionFilter("Shakespeare", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FilterByAttribution(root, filter); !errors.Is(err, ErrNoFortuneMatchesAttribution) {
+		t.Errorf("expected ErrNoFortuneMatchesAttribution, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package fortune
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// CursorsFileName is the name of the cursors file in the gofortune state
+// directory.
+const CursorsFileName = "cursors.json"
+
+// ErrEntryOutOfRange is returned when a collection has no entry at the
+// ordinal asked for.
+var ErrEntryOutOfRange = errors.New("entry out of range")
+
+// Cursors records how far each collection has been read in order, by
+// collection key as CollectionKey returns them, so that reading can resume
+// across invocations.
+type Cursors struct {
+	// Shown holds the ordinal of the entry of each collection shown last,
+	// counting from 0 in the order of GetFortuneAt.
+	Shown map[string]uint64 `json:"shown"`
+}
+
+// CollectionKey returns the key identifying in Cursors the collection made
+// of paths: their absolute paths, in order.
+func CollectionKey(paths []ProbabilityPath) string {
+	keys := make([]string, len(paths))
+	for i := range paths {
+		keys[i] = newHistoryKey(paths[i].Path, 0).path
+	}
+	return strings.Join(keys, string(os.PathListSeparator))
+}
+
+// countEntries returns the number of selectable entries under node.
+func countEntries(node FileSystemNodeDescriptor) uint64 {
+	var total uint64
+	walkLeaves(node, func(leaf FileSystemNodeDescriptor) {
+		total += leaf.NumEntries
+	})
+	return total
+}
+
+// GetFortuneAt returns the fortune at ordinal, counting from 0, of the
+// selectable entries of the tree: the leaves in the order they were loaded,
+// each in the order its index lists them, so that sorted or shuffled indexes
+// are read in their order. Returns ErrEntryOutOfRange past the last one.
+func GetFortuneAt(rootNode FileSystemNodeDescriptor, ordinal uint64) (Cookie, error) {
+	var found *FileSystemNodeDescriptor
+	var position uint32
+	remaining := ordinal
+	walkLeaves(rootNode, func(leaf FileSystemNodeDescriptor) {
+		switch {
+		case found != nil:
+		case remaining < leaf.NumEntries:
+			found, position = &leaf, leaf.entryPosition(int(remaining))
+		default:
+			remaining -= leaf.NumEntries
+		}
+	})
+	if found == nil {
+		return Cookie{}, fmt.Errorf("%w: %d of %d", ErrEntryOutOfRange, ordinal+1, countEntries(rootNode))
+	}
+	return readLeafEntry(*found, position)
+}
+
+// Next returns the entry of the tree following the one shown last for key,
+// starting over after the last one, and moves the cursor of key to it. The
+// first one is returned when nothing was shown yet.
+func (cursors *Cursors) Next(rootNode FileSystemNodeDescriptor, key string) (Cookie, error) {
+	total := countEntries(rootNode)
+	ordinal := uint64(0)
+	if shown, ok := cursors.Shown[key]; ok && total > 0 {
+		ordinal = (shown + 1) % total
+	}
+	return cursors.Goto(rootNode, key, ordinal)
+}
+
+// Prev returns the entry of the tree preceding the one shown last for key,
+// wrapping around to the last one, and moves the cursor of key to it. The
+// last one is returned when nothing was shown yet.
+func (cursors *Cursors) Prev(rootNode FileSystemNodeDescriptor, key string) (Cookie, error) {
+	total := countEntries(rootNode)
+	var ordinal uint64
+	if total > 0 {
+		ordinal = total - 1
+	}
+	if shown, ok := cursors.Shown[key]; ok && shown > 0 && shown <= total {
+		ordinal = shown - 1
+	}
+	return cursors.Goto(rootNode, key, ordinal)
+}
+
+// Goto returns the entry at ordinal of the tree, as GetFortuneAt does, and
+// moves the cursor of key to it.
+func (cursors *Cursors) Goto(rootNode FileSystemNodeDescriptor, key string, ordinal uint64) (Cookie, error) {
+	cookie, err := GetFortuneAt(rootNode, ordinal)
+	if err != nil {
+		return Cookie{}, err
+	}
+	if cursors.Shown == nil {
+		cursors.Shown = make(map[string]uint64)
+	}
+	cursors.Shown[key] = ordinal
+	return cookie, nil
+}
+
+// Reset forgets the cursor of key, so that Next starts over from the first
+// entry.
+func (cursors *Cursors) Reset(key string) {
+	delete(cursors.Shown, key)
+}
+
+// DefaultCursorsPath returns the path of the cursors file in the gofortune
+// directory of $XDG_STATE_HOME, or of ~/.local/state when it is not set.
+func DefaultCursorsPath() (string, error) {
+	return statePath(CursorsFileName)
+}
+
+// LoadCursors reads cursors written by SaveCursors.
+func LoadCursors(inputFile io.Reader) (Cursors, error) {
+	var cursors Cursors
+	if err := json.NewDecoder(inputFile).Decode(&cursors); err != nil {
+		return Cursors{}, fmt.Errorf("decode cursors: %w", err)
+	}
+	return cursors, nil
+}
+
+// LoadCursorsFromPath reads the cursors file at inputFilePath, returning no
+// cursors when there is none yet.
+func LoadCursorsFromPath(inputFilePath string) (Cursors, error) {
+	inputFile, err := os.Open(inputFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Cursors{}, nil
+	}
+	if err != nil {
+		return Cursors{}, err
+	}
+	defer func() { _ = inputFile.Close() }()
+	return LoadCursors(inputFile)
+}
+
+// SaveCursors writes cursors to outputFile.
+func SaveCursors(outputFile io.Writer, cursors Cursors) error {
+	if err := json.NewEncoder(outputFile).Encode(cursors); err != nil {
+		return fmt.Errorf("encode cursors: %w", err)
+	}
+	return nil
+}
+
+// SaveCursorsToPath replaces the cursors file at outputFilePath with
+// cursors, creating its directory if needed.
+func SaveCursorsToPath(outputFilePath string, cursors Cursors) error {
+	if err := os.MkdirAll(filepath.Dir(outputFilePath), 0700); err != nil {
+		return err
+	}
+	return pkg.WriteFileAtomically(outputFilePath, func(outputFile *os.File) error {
+		return SaveCursors(outputFile, cursors)
+	})
+}
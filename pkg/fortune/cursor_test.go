@@ -0,0 +1,64 @@
+package fortune
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vromero/gofortune/pkg/strfile"
+)
+
+// TestCursorsFollowIndexOrder verifies that a cursor walks a collection in
+// the order of its indexes, across files, and wraps around both ends.
+func TestCursorsFollowIndexOrder(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a": "b\n%\na\n%\n", "b": "c\n%\n"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := strfile.StrFile(path, path+".dat", strfile.Options{Order: true}); err != nil {
+			t.Fatalf("strfile: %v", err)
+		}
+	}
+	paths := []ProbabilityPath{{Path: dir}}
+	root, err := LoadPaths(paths, math.MaxUint32, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	key := CollectionKey(paths)
+
+	var cursors Cursors
+	var got []string
+	step := func(move func(FileSystemNodeDescriptor, string) (Cookie, error)) {
+		cookie, err := move(root, key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, cookie.Data)
+	}
+	for i := 0; i < 4; i++ {
+		step(cursors.Next)
+	}
+	cursors.Reset(key)
+	step(cursors.Prev)
+	step(cursors.Prev)
+	want := []string{"a", "b", "c", "a", "c", "b"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+
+	if _, err := cursors.Goto(root, key, 3); !errors.Is(err, ErrEntryOutOfRange) {
+		t.Errorf("expected ErrEntryOutOfRange, got %v", err)
+	}
+	if cursors.Shown[key] != 1 {
+		t.Errorf("a failed goto moved the cursor to %d", cursors.Shown[key])
+	}
+}
@@ -0,0 +1,30 @@
+package fortune
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand/v2"
+	"time"
+)
+
+// DailySeed returns the seed of the fortune of the day of date: the same on
+// every host for the same calendar day in date's location and salt, and
+// different from one day to the next. Sites sharing collections use salt to
+// pick a different fortune than each other.
+func DailySeed(date time.Time, salt string) uint64 {
+	sum := sha256.Sum256([]byte(date.Format(time.DateOnly) + "\x00" + salt))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// NewDailyRand returns the generator the fortune of the day of date is drawn
+// from; see DailySeed.
+func NewDailyRand(date time.Time, salt string) *rand.Rand {
+	return NewSeededRand(DailySeed(date, salt))
+}
+
+// GetDailyFortune picks the fortune of the day of date, weighting the tree as
+// GetRandomFortune does. Hosts with identical collections loaded in the same
+// order, and probabilities set the same way, pick the same fortune all day.
+func GetDailyFortune(rootNode FileSystemNodeDescriptor, date time.Time, salt string) (Cookie, error) {
+	return GetRandomFortuneWith(rootNode, NewDailyRand(date, salt))
+}
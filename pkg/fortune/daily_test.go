@@ -0,0 +1,47 @@
+package fortune
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGetDailyFortune verifies that the fortune of the day only depends on
+// the calendar day in the date's location and on the salt.
+func TestGetDailyFortune(t *testing.T) {
+	var content strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&content, "fortune %d\n%%\n", i)
+	}
+	path := writeIndexedFortuneFile(t, content.String(), "%", false)
+	root, err := LoadPaths([]ProbabilityPath{{Path: path}}, 10000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	SetProbabilities(&root, false)
+
+	daily := func(date time.Time, salt string) string {
+		cookie, err := GetDailyFortune(root, date, salt)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return cookie.Data
+	}
+	tokyo := time.FixedZone("JST", 9*60*60)
+	morning := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	evening := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	if daily(morning, "") != daily(evening, "") {
+		t.Errorf("the fortune changed during the day")
+	}
+	// 23:00 UTC is already the next day in Tokyo.
+	if daily(evening, "") == daily(evening.In(tokyo), "") {
+		t.Errorf("the fortune did not follow the day in the location")
+	}
+	if daily(morning, "") == daily(morning.AddDate(0, 0, 1), "") {
+		t.Errorf("the fortune did not change with the day")
+	}
+	if daily(morning, "") == daily(morning, "team") {
+		t.Errorf("the fortune did not change with the salt")
+	}
+}
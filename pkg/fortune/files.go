@@ -3,6 +3,7 @@ package fortune
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 
@@ -14,6 +15,10 @@ import (
 // caller's length filter. Callers should treat this as a non-fatal skip.
 var ErrLengthFilterExcluded = errors.New("file does not honor the length filter")
 
+// ErrStaleIndex reports an index file built from a different version of its
+// fortune file.
+var ErrStaleIndex = errors.New("index is out of date")
+
 // ProbabilityPath pairs a filesystem path with the percentage probability the
 // caller wants it to be randomly selected. Path should point only to
 // directories containing fortune files or to a fortune file that has a
@@ -23,8 +28,72 @@ type ProbabilityPath struct {
 	Percentage float32
 }
 
+// StaleIndexPolicy tells LoadPathsWithOptions what to do with an index file
+// whose fortune file changed after the index was built.
+type StaleIndexPolicy int
+
+const (
+	// StaleIndexReindex ignores the stale index file and indexes the fortune
+	// file in memory instead.
+	StaleIndexReindex StaleIndexPolicy = iota
+	// StaleIndexWarn reports the stale index through LoadOptions.Warn and
+	// keeps using it.
+	StaleIndexWarn
+	// StaleIndexRewrite rebuilds the index file with strfile, falling back to
+	// StaleIndexReindex when it cannot be written.
+	StaleIndexRewrite
+)
+
+var staleIndexPolicyNames = []string{"reindex", "warn", "rewrite"}
+
+func (policy StaleIndexPolicy) String() string {
+	if policy < 0 || int(policy) >= len(staleIndexPolicyNames) {
+		return fmt.Sprintf("StaleIndexPolicy(%d)", int(policy))
+	}
+	return staleIndexPolicyNames[policy]
+}
+
+// ParseStaleIndexPolicy returns the policy called name: "reindex", "warn" or
+// "rewrite".
+func ParseStaleIndexPolicy(name string) (StaleIndexPolicy, error) {
+	for i := range staleIndexPolicyNames {
+		if staleIndexPolicyNames[i] == name {
+			return StaleIndexPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid stale index policy %q, expected one of %v", name, staleIndexPolicyNames)
+}
+
+// LoadOptions tunes how LoadPathsWithOptions treats fortune files.
+type LoadOptions struct {
+	// AutoIndex builds in memory the index of fortune files that have no
+	// ".dat" index, so freshly written files are read without running
+	// strfile first.
+	AutoIndex bool
+	// StaleIndex decides what happens to index files that no longer match
+	// their fortune file.
+	StaleIndex StaleIndexPolicy
+	// Warn, when set, receives the problems found while loading that do not
+	// prevent a file from being used, such as stale indexes.
+	Warn func(err error)
+}
+
+// DefaultLoadOptions are the options LoadPaths loads paths with.
+var DefaultLoadOptions = LoadOptions{AutoIndex: true, StaleIndex: StaleIndexReindex}
+
+func (options LoadOptions) warn(err error) {
+	if options.Warn != nil {
+		options.Warn(err)
+	}
+}
+
 // FileSystemNodeDescriptor is a node in the fortune tree: a directory (with
-// Children) or a leaf fortune file (with IndexPath and Table populated).
+// Children) or a leaf fortune file (with IndexPath or IndexData, Format,
+// Table and Extensions populated).
+//
+// Percent holds the N% weight of a path until SetProbabilities replaces it
+// with the chance out of 100 of picking the node; see exactWeight for what
+// picks follow.
 type FileSystemNodeDescriptor struct {
 	Percent                  float32
 	UndefinedChildrenPercent float32 // Total percentage non user-defined for this node
@@ -33,9 +102,15 @@ type FileSystemNodeDescriptor struct {
 	NumFiles                 int    // Total number of files
 	Path                     string
 	IndexPath                string
+	IndexData                []byte // In-memory index, for files without an index file
+	Format                   pkg.IndexFormat
 	Table                    pkg.DataTable
+	Extensions               pkg.IndexExtensions
+	Entries                  []uint32 // Positions of the entries a filter left selectable; all of them when nil
 	Children                 []FileSystemNodeDescriptor
 	Parent                   *FileSystemNodeDescriptor
+	weight                   *big.Rat   // Exact odds of a leaf, see exactWeight
+	leafTable                *leafTable // Built by SetProbabilities; nil once the odds may have changed
 }
 
 // LoadPaths loads the paths described in the paths argument and returns a
@@ -44,20 +119,27 @@ type FileSystemNodeDescriptor struct {
 //
 // LoadPaths can filter fortune files by their shortest/longest dictum, which
 // is useful to prevent infinite loops in length-constrained random picks.
+//
+// LoadPaths uses DefaultLoadOptions; see LoadPathsWithOptions.
 func LoadPaths(paths []ProbabilityPath, shorterThan uint32, longerThan uint32) (FileSystemNodeDescriptor, error) {
+	return LoadPathsWithOptions(paths, shorterThan, longerThan, DefaultLoadOptions)
+}
+
+// LoadPathsWithOptions is LoadPaths with explicit LoadOptions.
+func LoadPathsWithOptions(paths []ProbabilityPath, shorterThan uint32, longerThan uint32, options LoadOptions) (FileSystemNodeDescriptor, error) {
 	rootFsDescriptor := FileSystemNodeDescriptor{
 		Percent: 100,
 	}
 
 	for i := range paths {
-		if err := loadPath(paths[i], &rootFsDescriptor, shorterThan, longerThan); err != nil {
+		if err := loadPath(paths[i], &rootFsDescriptor, shorterThan, longerThan, options); err != nil {
 			return rootFsDescriptor, err
 		}
 	}
 	return rootFsDescriptor, nil
 }
 
-func loadPath(path ProbabilityPath, parent *FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32) error {
+func loadPath(path ProbabilityPath, parent *FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32, options LoadOptions) error {
 	fsDescriptor := FileSystemNodeDescriptor{
 		Path:    path.Path,
 		Percent: path.Percentage,
@@ -69,12 +151,12 @@ func loadPath(path ProbabilityPath, parent *FileSystemNodeDescriptor, shorterTha
 		return fmt.Errorf("stat %q: %w", path.Path, err)
 	}
 	if stat.IsDir() {
-		return loadDirPath(&fsDescriptor, parent, shorterThan, longerThan)
+		return loadDirPath(&fsDescriptor, parent, shorterThan, longerThan, options)
 	}
-	return loadFilePath(&fsDescriptor, parent, shorterThan, longerThan)
+	return loadFilePath(&fsDescriptor, parent, shorterThan, longerThan, options)
 }
 
-func loadDirPath(fsDescriptor *FileSystemNodeDescriptor, parent *FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32) error {
+func loadDirPath(fsDescriptor *FileSystemNodeDescriptor, parent *FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32, options LoadOptions) error {
 	entries, err := os.ReadDir(fsDescriptor.Path)
 	if err != nil {
 		return fmt.Errorf("read directory %q: %w", fsDescriptor.Path, err)
@@ -87,13 +169,18 @@ func loadDirPath(fsDescriptor *FileSystemNodeDescriptor, parent *FileSystemNodeD
 		if entry.IsDir() {
 			continue
 		}
+		// Index files and the other companions fortune(6) ignores are never
+		// fortune files, even when they lack an index of their own.
+		if pkg.IsIgnoredFileName(entry.Name()) {
+			continue
+		}
 		childFsDescriptor := FileSystemNodeDescriptor{
 			Path:   filepath.Join(fsDescriptor.Path, entry.Name()),
 			Parent: fsDescriptor,
 		}
 		// Files that are not valid fortune files or that fail the length
 		// filter are silently skipped for compatibility with fortune(6).
-		_ = loadFilePath(&childFsDescriptor, fsDescriptor, shorterThan, longerThan)
+		_ = loadFilePath(&childFsDescriptor, fsDescriptor, shorterThan, longerThan, options)
 	}
 
 	fsDescriptor.Parent = parent
@@ -101,27 +188,25 @@ func loadDirPath(fsDescriptor *FileSystemNodeDescriptor, parent *FileSystemNodeD
 	return nil
 }
 
-func loadFilePath(fsDescriptor *FileSystemNodeDescriptor, parent *FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32) error {
+func loadFilePath(fsDescriptor *FileSystemNodeDescriptor, parent *FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32, options LoadOptions) error {
 	if !isFortuneFile(fsDescriptor.Path) {
 		return fmt.Errorf("%q is not a valid fortune file", fsDescriptor.Path)
 	}
 
-	indexPath := fsDescriptor.Path + ".dat"
-	if !isFortuneIndexFile(indexPath) {
-		return fmt.Errorf("%q is not a valid fortune index file", indexPath)
+	if err := loadLeafIndex(fsDescriptor, options); err != nil {
+		return err
 	}
-	fsDescriptor.IndexPath = indexPath
-
-	table, err := pkg.LoadDataTableFromPath(fsDescriptor.IndexPath)
-	if err != nil {
-		return fmt.Errorf("load data table from %q: %w", fsDescriptor.IndexPath, err)
+	// Files holding no fortune, such as a README indexed in memory, would
+	// still take a share of the odds with -e.
+	if fsDescriptor.Table.NumberOfStrings == 0 {
+		return fmt.Errorf("%w: %q", ErrEmptyFortuneFile, fsDescriptor.Path)
 	}
 
+	table := fsDescriptor.Table
 	if table.LongestLength < longerThan || table.ShortestLength > shorterThan {
 		return ErrLengthFilterExcluded
 	}
 
-	fsDescriptor.Table = table
 	fsDescriptor.Parent = parent
 
 	populateFileAmounts(fsDescriptor, table)
@@ -142,16 +227,3 @@ func populateFileAmounts(fsDescriptor *FileSystemNodeDescriptor, table pkg.DataT
 func isFortuneFile(path string) bool {
 	return pkg.FileExists(path)
 }
-
-// isFortuneIndexFile reports whether path is an existing fortune index file
-// of the supported version.
-func isFortuneIndexFile(path string) bool {
-	if !pkg.FileExists(path) {
-		return false
-	}
-	version, err := pkg.LoadDataTableVersionFromPath(path)
-	if err != nil || version.Version != pkg.DefaultVersion {
-		return false
-	}
-	return true
-}
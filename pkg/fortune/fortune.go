@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"math/rand/v2"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -17,14 +16,35 @@ import (
 )
 
 // maxLengthFilterAttempts bounds the number of random picks
-// GetLengthFilteredRandomFortune is willing to make before giving up, to avoid
-// infinite loops when no fortune satisfies the length constraint.
+// GetLengthFilteredRandomFortune is willing to make before giving up on
+// collections whose indexes do not record entry lengths, to avoid infinite
+// loops when no fortune satisfies the length constraint.
 const maxLengthFilterAttempts = 1000
 
 // Cookie is a single fortune cookie together with the file it came from.
+// Data always holds the readable text; Rotated reports whether the file
+// stores it rot13'd, in which case RawData returns the stored form. The
+// Author, Source and Year of the Attribution come from the trailer ending
+// Data, if any.
 type Cookie struct {
 	Data     string
 	FileName string
+	// Path is the path of the fortune file as loaded, and Entry the
+	// position of the cookie in its index.
+	Path    string
+	Entry   uint32
+	Rotated bool
+	pkg.Attribution
+}
+
+// RawData returns the cookie text exactly as stored in its fortune file.
+func (c Cookie) RawData() string {
+	if c.Rotated {
+		// rot13 is its own inverse, so encoding the decoded text again
+		// yields the original bytes.
+		return pkg.Rot13(c.Data)
+	}
+	return c.Data
 }
 
 // Request describes a fortune-selection request as produced by PrepareRequest
@@ -33,8 +53,15 @@ type Request struct {
 	AllMaxims, ShowCookieFile, PrintListOfFiles bool
 	LongDictumsOnly, ShortOnly, IgnoreCase      bool
 	Wait, ConsiderAllEqual, Offensive           bool
-	Match                                       string
-	LongestShort                                int
+	Unrotated, AutoIndex                        bool
+	Seeded, ShowSeed, CryptoRand, Daily         bool
+	ShuffleBag, Next, Prev, Reset, Goto         bool
+	Match, Author, Source                       string
+	Timezone, Salt, HistoryPath, CursorsPath    string
+	LongestShort, NoRepeat, NoRepeatDays        int
+	Seed, GotoEntry                             uint64
+	StaleIndex                                  StaleIndexPolicy
+	Strategy                                    SelectionStrategy
 	Paths                                       []ProbabilityPath
 	OffensivePaths                              []ProbabilityPath
 }
@@ -84,47 +111,46 @@ func selectExisting(path1 string, path2 string) string {
 
 // GetRandomFortune picks one fortune from a random leaf of the descriptor tree.
 func GetRandomFortune(rootNode FileSystemNodeDescriptor) (Cookie, error) {
-	randomNode, err := GetRandomLeafNode(rootNode)
+	return GetRandomFortuneWith(rootNode, nil)
+}
+
+// GetRandomFortuneWith is GetRandomFortune drawing from random; nil draws
+// from the top-level generator of math/rand/v2. A generator from
+// NewSeededRand makes the pick reproducible for the same tree.
+func GetRandomFortuneWith(rootNode FileSystemNodeDescriptor, random *rand.Rand) (Cookie, error) {
+	randomNode, err := GetRandomLeafNodeWith(rootNode, random)
 	if err != nil {
 		return Cookie{}, err
 	}
 	if randomNode.NumEntries == 0 {
 		return Cookie{}, fmt.Errorf("fortune file %q is empty", randomNode.Path)
 	}
-	randomEntry := rand.IntN(int(randomNode.NumEntries))
-
-	indexFile, err := os.Open(randomNode.IndexPath)
-	if err != nil {
-		return Cookie{}, fmt.Errorf("open index file %q: %w", randomNode.IndexPath, err)
-	}
-	defer func() { _ = indexFile.Close() }()
-
-	dataPos, err := pkg.ReadDataPos(indexFile, int(pkg.DataTableSize), uint32(randomEntry))
-	if err != nil {
-		return Cookie{}, fmt.Errorf("read index file %q: %w", randomNode.IndexPath, err)
-	}
-
-	fortuneFile, err := os.Open(randomNode.Path)
-	if err != nil {
-		return Cookie{}, fmt.Errorf("open fortune file %q: %w", randomNode.Path, err)
-	}
-	defer func() { _ = fortuneFile.Close() }()
-
-	data, err := pkg.ReadData(fortuneFile, int64(dataPos.OriginalOffset))
-	if err != nil {
-		return Cookie{}, fmt.Errorf("read fortune file %q: %w", randomNode.Path, err)
-	}
+	randomEntry := randOrDefault(random).IntN(int(randomNode.NumEntries))
 
-	return Cookie{FileName: filepath.Base(randomNode.Path), Data: data}, nil
+	return readLeafEntry(randomNode, randomNode.entryPosition(randomEntry))
 }
 
 // GetLengthFilteredRandomFortune picks a random fortune whose length is in the
-// open interval (longerThan, shorterThan). It gives up after
-// maxLengthFilterAttempts tries, returning an error, to avoid infinite loops
-// when no fortune satisfies the constraint.
+// open interval (longerThan, shorterThan).
+//
+// When every index records its entry lengths the pick is made among the
+// fortunes that fit, and ErrNoFortuneMatchesLength is returned only if there
+// are none. Otherwise random fortunes are drawn until one fits, giving up
+// after maxLengthFilterAttempts tries, also with ErrNoFortuneMatchesLength,
+// to avoid infinite loops when no fortune satisfies the constraint.
 func GetLengthFilteredRandomFortune(rootNode FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32) (Cookie, error) {
+	return GetLengthFilteredRandomFortuneWith(rootNode, shorterThan, longerThan, nil)
+}
+
+// GetLengthFilteredRandomFortuneWith is GetLengthFilteredRandomFortune
+// drawing from random; nil draws from the top-level generator of
+// math/rand/v2.
+func GetLengthFilteredRandomFortuneWith(rootNode FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32, random *rand.Rand) (Cookie, error) {
+	if hasEntryLengths(rootNode) {
+		return getExactLengthFilteredRandomFortune(rootNode, shorterThan, longerThan, random)
+	}
 	for i := 0; i < maxLengthFilterAttempts; i++ {
-		cookie, err := GetRandomFortune(rootNode)
+		cookie, err := GetRandomFortuneWith(rootNode, random)
 		if err != nil {
 			return Cookie{}, err
 		}
@@ -133,7 +159,7 @@ func GetLengthFilteredRandomFortune(rootNode FileSystemNodeDescriptor, shorterTh
 			return cookie, nil
 		}
 	}
-	return Cookie{}, fmt.Errorf("no fortune found matching length constraints after %d attempts", maxLengthFilterAttempts)
+	return Cookie{}, fmt.Errorf("%w after %d attempts", ErrNoFortuneMatchesLength, maxLengthFilterAttempts)
 }
 
 // GetFortunesMatching streams all fortunes matching expression. Returns a data
@@ -178,41 +204,25 @@ func walkMatching(node FileSystemNodeDescriptor, expression *regexp.Regexp, outp
 }
 
 func scanLeafForMatches(node FileSystemNodeDescriptor, expression *regexp.Regexp, output chan<- Cookie, errorOutput chan<- error) {
-	indexFile, err := os.Open(node.IndexPath)
+	reader, err := openLeaf(node)
 	if err != nil {
-		errorOutput <- fmt.Errorf("open index file %q: %w", node.IndexPath, err)
+		errorOutput <- err
 		return
 	}
-	defer func() { _ = indexFile.Close() }()
+	defer func() { _ = reader.Close() }()
 
-	fortuneFile, err := os.Open(node.Path)
-	if err != nil {
-		errorOutput <- fmt.Errorf("open fortune file %q: %w", node.Path, err)
-		return
-	}
-	defer func() { _ = fortuneFile.Close() }()
-
-	fileName := filepath.Base(node.Path)
-	for i := int64(0); i < int64(node.NumEntries); i++ {
-		dataPos, err := pkg.ReadDataPos(indexFile, int(pkg.DataTableSize), uint32(i))
+	for i := 0; i < int(node.NumEntries); i++ {
+		cookie, err := reader.entry(node.entryPosition(i))
 		if err != nil {
-			errorOutput <- fmt.Errorf("read index file %q entry %d: %w", node.IndexPath, i, err)
+			errorOutput <- err
 			continue
 		}
-
-		data, err := pkg.ReadData(fortuneFile, int64(dataPos.OriginalOffset))
-		if err != nil {
-			errorOutput <- fmt.Errorf("read fortune file %q entry %d: %w", node.Path, i, err)
-			continue
-		}
-		if expression.MatchString(data) {
-			output <- Cookie{FileName: fileName, Data: data}
+		if expression.MatchString(cookie.Data) {
+			output <- cookie
 		}
 	}
 }
 
-// Sentinel errors retained for callers that may want to programmatically
-// detect them (not used internally; kept for API surface).
-var (
-	ErrEmptyFortuneFile = errors.New("fortune file is empty")
-)
+// ErrEmptyFortuneFile is returned by LoadPaths for a fortune file path
+// holding no fortune; such files found in directories are skipped.
+var ErrEmptyFortuneFile = errors.New("fortune file is empty")
@@ -1,14 +1,36 @@
 package fortune
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/vromero/gofortune/pkg"
+	"github.com/vromero/gofortune/pkg/strfile"
 )
 
+// writeIndexedFortuneFile writes content to a fortune file in a temporary
+// directory and indexes it with strfile, returning the fortune file path.
+func writeIndexedFortuneFile(t *testing.T, content string, delimiter string, rot13 bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fortunes")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write fortune file: %v", err)
+	}
+	if _, err := strfile.StrFile(path, path+".dat", strfile.Options{DelimitingChar: delimiter, Rot13: rot13}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+	return path
+}
+
 // TestGetRandomLeafNodeNoChildren: a leaf node returns itself, no error.
 func TestGetRandomLeafNodeNoChildren(t *testing.T) {
 	leaf := FileSystemNodeDescriptor{Path: "/tmp/fortune", NumEntries: 3}
@@ -145,3 +167,215 @@ func TestGetLengthFilteredRandomFortuneGivesUp(t *testing.T) {
 	}
 }
 
+// TestGetRandomFortuneDecodesRotated verifies that entries of a collection
+// indexed as rot13'd are returned decoded, with RawData giving the stored text.
+func TestGetRandomFortuneDecodesRotated(t *testing.T) {
+	path := writeIndexedFortuneFile(t, "Uryyb jbeyq\n%\n", "%", true)
+	root, err := LoadPaths([]ProbabilityPath{{Path: path}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	SetProbabilities(&root, false)
+
+	cookie, err := GetRandomFortune(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cookie.Data != "Hello world" {
+		t.Errorf("expected decoded %q, got %q", "Hello world", cookie.Data)
+	}
+	if !cookie.Rotated || cookie.RawData() != "Uryyb jbeyq" {
+		t.Errorf("expected raw %q, got %q (rotated=%v)", "Uryyb jbeyq", cookie.RawData(), cookie.Rotated)
+	}
+}
+
+// TestGetFortunesMatchingRotated verifies that -m patterns are matched
+// against the decoded text of rot13'd collections.
+func TestGetFortunesMatchingRotated(t *testing.T) {
+	path := writeIndexedFortuneFile(t, "Uryyb jbeyq\n%\nTbbqolr\n%\n", "%", true)
+	root, err := LoadPaths([]ProbabilityPath{{Path: path}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+
+	dataCh, errCh := GetFortunesMatching(root, "^Hello", false)
+	var matches []string
+	for dataCh != nil || errCh != nil {
+		select {
+		case cookie, ok := <-dataCh:
+			if !ok {
+				dataCh = nil
+				continue
+			}
+			matches = append(matches, cookie.Data)
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if len(matches) != 1 || matches[0] != "Hello world" {
+		t.Errorf("expected one decoded match, got %q", matches)
+	}
+}
+
+// TestGetFortunesMatchingCustomDelimiter verifies that collections indexed
+// with a delimiter other than "%" are split on it instead of running together.
+func TestGetFortunesMatchingCustomDelimiter(t *testing.T) {
+	path := writeIndexedFortuneFile(t, "first\n#\nsecond\n100%\n#\nthird\n#\n", "#", false)
+	root, err := LoadPaths([]ProbabilityPath{{Path: path}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+
+	dataCh, errCh := GetFortunesMatching(root, ".", false)
+	var matches []string
+	for dataCh != nil || errCh != nil {
+		select {
+		case cookie, ok := <-dataCh:
+			if !ok {
+				dataCh = nil
+				continue
+			}
+			matches = append(matches, cookie.Data)
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	expected := []string{"first", "second\n100%", "third"}
+	if strings.Join(matches, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %q, got %q", expected, matches)
+	}
+}
+
+// TestGetRandomFortuneMultiByteDelimiter verifies that entries of a
+// collection indexed with a UTF-8 delimiter the header cannot hold are read
+// up to the whole delimiter line.
+func TestGetRandomFortuneMultiByteDelimiter(t *testing.T) {
+	path := writeIndexedFortuneFile(t, "only\n§x\n§\n", "§", false)
+	root, err := LoadPaths([]ProbabilityPath{{Path: path}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	SetProbabilities(&root, false)
+
+	cookie, err := GetRandomFortune(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cookie.Data != "only\n§x" {
+		t.Errorf("expected %q, got %q", "only\n§x", cookie.Data)
+	}
+}
+
+// TestGetRandomFortuneWithSeed verifies that generators from the same seed
+// pick the same sequence of fortunes, and that a crypto/rand generator picks
+// from the collection too.
+func TestGetRandomFortuneWithSeed(t *testing.T) {
+	var content strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&content, "fortune %d\n%%\n", i)
+	}
+	path := writeIndexedFortuneFile(t, content.String(), "%", false)
+	root, err := LoadPaths([]ProbabilityPath{{Path: path}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	SetProbabilities(&root, false)
+
+	picks := func(random *rand.Rand) []string {
+		var data []string
+		for i := 0; i < 10; i++ {
+			cookie, err := GetRandomFortuneWith(root, random)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data = append(data, cookie.Data)
+		}
+		return data
+	}
+	first, second := picks(NewSeededRand(42)), picks(NewSeededRand(42))
+	if !slices.Equal(first, second) {
+		t.Errorf("same seed picked %q then %q", first, second)
+	}
+	if other := picks(NewSeededRand(43)); slices.Equal(first, other) {
+		t.Errorf("seeds 42 and 43 picked the same %q", first)
+	}
+	for _, data := range picks(NewCryptoRand()) {
+		if !strings.HasPrefix(data, "fortune ") {
+			t.Errorf("unexpected fortune %q", data)
+		}
+	}
+}
+
+// TestLoadPathsBSDIndex verifies that an index with 64-bit offsets, as
+// written by the BSD strfile, is loaded instead of being skipped.
+func TestLoadPathsBSDIndex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fortunes")
+	if err := os.WriteFile(path, []byte("first\n%\nsecond\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := new(bytes.Buffer)
+	_ = binary.Write(index, binary.BigEndian, pkg.DataTable{Version: pkg.DefaultVersion, NumberOfStrings: 2, LongestLength: 7, ShortestLength: 6, Delimiter: '%'})
+	_ = binary.Write(index, binary.BigEndian, []uint64{0, 8, 17})
+	if err := os.WriteFile(path+".dat", index.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := LoadPaths([]ProbabilityPath{{Path: dir}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	if root.NumFiles != 1 || root.NumEntries != 2 {
+		t.Fatalf("expected 1 file with 2 entries, got %d files with %d entries", root.NumFiles, root.NumEntries)
+	}
+	leaf := root.Children[0].Children[0]
+	if leaf.Format.OffsetSize != 8 {
+		t.Errorf("expected 64-bit offsets, got %v", leaf.Format)
+	}
+
+	reader, err := openLeaf(leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader.Close() }()
+	cookie, err := reader.entry(1)
+	if err != nil || cookie.Data != "second" {
+		t.Errorf("expected %q, got %q (err=%v)", "second", cookie.Data, err)
+	}
+}
+
+// TestGetLengthFilteredRandomFortuneExact verifies that the only short entry
+// of a collection is found every time, and that a filter nothing satisfies
+// reports ErrNoFortuneMatchesLength instead of retrying.
+func TestGetLengthFilteredRandomFortuneExact(t *testing.T) {
+	long := strings.Repeat("a long fortune line\n", 5)
+	content := strings.Repeat(long+"%\n", 50) + "short\n%\n" + strings.Repeat(long+"%\n", 50)
+	path := writeIndexedFortuneFile(t, content, "%", false)
+	root, err := LoadPaths([]ProbabilityPath{{Path: path}}, 10, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	SetProbabilities(&root, false)
+
+	for i := 0; i < 20; i++ {
+		cookie, err := GetLengthFilteredRandomFortune(root, 10, 0)
+		if err != nil {
+			t.Fatalf("iter %d: unexpected error: %v", i, err)
+		}
+		if cookie.Data != "short" {
+			t.Fatalf("iter %d: expected %q, got %q", i, "short", cookie.Data)
+		}
+	}
+
+	if _, err := GetLengthFilteredRandomFortune(root, 5, 0); !errors.Is(err, ErrNoFortuneMatchesLength) {
+		t.Errorf("expected ErrNoFortuneMatchesLength, got %v", err)
+	}
+}
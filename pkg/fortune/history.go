@@ -0,0 +1,226 @@
+package fortune
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// HistoryFileName is the name of the history file in the gofortune state
+// directory.
+const HistoryFileName = "history.json"
+
+// HistoryEntry records a fortune that was shown.
+type HistoryEntry struct {
+	// Path is the absolute path of the fortune file, and Entry the position
+	// of the fortune in its index.
+	Path  string    `json:"path"`
+	Entry uint32    `json:"entry"`
+	Time  time.Time `json:"time"`
+}
+
+// History lists the fortunes shown, oldest first, so that
+// GetUnseenRandomFortuneWith can avoid repeating them.
+type History struct {
+	Entries []HistoryEntry `json:"entries"`
+}
+
+// HistoryWindow tells which fortunes of a History count as recently seen.
+// The zero value counts none.
+type HistoryWindow struct {
+	// Last counts the Last fortunes shown most recently.
+	Last int
+	// Days counts the fortunes shown in the last Days days.
+	Days int
+	// ShuffleBag counts every fortune shown since the fortunes eligible were
+	// last exhausted, so each is shown once before any repeats.
+	ShuffleBag bool
+}
+
+// IsZero reports whether window counts no fortune as recently seen.
+func (window HistoryWindow) IsZero() bool {
+	return window == HistoryWindow{}
+}
+
+// historyKey identifies an entry of a fortune file in a History.
+type historyKey struct {
+	path  string
+	entry uint32
+}
+
+func newHistoryKey(path string, entry uint32) historyKey {
+	if absolute, err := filepath.Abs(path); err == nil {
+		path = absolute
+	}
+	return historyKey{path: path, entry: entry}
+}
+
+// recent returns the positions of history's entries that window counts as
+// recently seen at now.
+func (history History) recent(window HistoryWindow, now time.Time) []int {
+	var positions []int
+	for i, entry := range history.Entries {
+		switch {
+		case window.ShuffleBag,
+			window.Last > 0 && i >= len(history.Entries)-window.Last,
+			window.Days > 0 && now.Sub(entry.Time) < time.Duration(window.Days)*24*time.Hour:
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// Record adds cookie to history as shown at now, forgetting the entries
+// window no longer counts as recently seen.
+func (history *History) Record(cookie Cookie, window HistoryWindow, now time.Time) {
+	key := newHistoryKey(cookie.Path, cookie.Entry)
+	history.Entries = append(history.Entries, HistoryEntry{Path: key.path, Entry: key.entry, Time: now})
+	var kept []HistoryEntry
+	for _, i := range history.recent(window, now) {
+		kept = append(kept, history.Entries[i])
+	}
+	history.Entries = kept
+}
+
+// forget removes the entries of the fortune files under node from history.
+func (history *History) forget(node FileSystemNodeDescriptor) {
+	paths := make(map[string]bool)
+	walkLeaves(node, func(leaf FileSystemNodeDescriptor) {
+		paths[newHistoryKey(leaf.Path, 0).path] = true
+	})
+	var kept []HistoryEntry
+	for _, entry := range history.Entries {
+		if !paths[entry.Path] {
+			kept = append(kept, entry)
+		}
+	}
+	history.Entries = kept
+}
+
+// walkLeaves calls visit with every leaf under node.
+func walkLeaves(node FileSystemNodeDescriptor, visit func(leaf FileSystemNodeDescriptor)) {
+	if len(node.Children) == 0 {
+		visit(node)
+		return
+	}
+	for i := range node.Children {
+		walkLeaves(node.Children[i], visit)
+	}
+}
+
+// GetUnseenRandomFortuneWith is GetLengthFilteredRandomFortuneWith skipping
+// the fortunes window counts as recently seen in history, and recording the
+// one picked there at now.
+//
+// When every eligible fortune was seen, a shuffle bag is emptied of the
+// fortune files loaded and the pick made among all of them; otherwise the
+// pick is made among all of them as if there were no history.
+func GetUnseenRandomFortuneWith(rootNode FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32, history *History, window HistoryWindow, now time.Time, random *rand.Rand) (Cookie, error) {
+	seen := make(map[historyKey]bool)
+	for _, i := range history.recent(window, now) {
+		entry := history.Entries[i]
+		seen[historyKey{path: entry.Path, entry: entry.Entry}] = true
+	}
+
+	unseen, ok, _ := filterEntries(rootNode, func(leaf FileSystemNodeDescriptor) ([]uint32, error) {
+		path := newHistoryKey(leaf.Path, 0).path
+		var positions []uint32
+		for i := 0; i < int(leaf.NumEntries); i++ {
+			if position := leaf.entryPosition(i); !seen[historyKey{path: path, entry: position}] {
+				positions = append(positions, position)
+			}
+		}
+		return positions, nil
+	})
+	if ok {
+		cookie, err := GetLengthFilteredRandomFortuneWith(unseen, shorterThan, longerThan, random)
+		if err == nil {
+			history.Record(cookie, window, now)
+			return cookie, nil
+		}
+		if !errors.Is(err, ErrNoFortuneMatchesLength) {
+			return Cookie{}, err
+		}
+	}
+
+	if window.ShuffleBag {
+		history.forget(rootNode)
+	}
+	cookie, err := GetLengthFilteredRandomFortuneWith(rootNode, shorterThan, longerThan, random)
+	if err != nil {
+		return Cookie{}, err
+	}
+	history.Record(cookie, window, now)
+	return cookie, nil
+}
+
+// DefaultHistoryPath returns the path of the history file in the gofortune
+// directory of $XDG_STATE_HOME, or of ~/.local/state when it is not set.
+func DefaultHistoryPath() (string, error) {
+	return statePath(HistoryFileName)
+}
+
+// statePath returns the path of the file name in the gofortune directory of
+// $XDG_STATE_HOME, or of ~/.local/state when it is not set.
+func statePath(name string) (string, error) {
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if !filepath.IsAbs(stateDir) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("locate state directory: %w", err)
+		}
+		stateDir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateDir, "gofortune", name), nil
+}
+
+// LoadHistory reads a history written by SaveHistory.
+func LoadHistory(inputFile io.Reader) (History, error) {
+	var history History
+	if err := json.NewDecoder(inputFile).Decode(&history); err != nil {
+		return History{}, fmt.Errorf("decode history: %w", err)
+	}
+	return history, nil
+}
+
+// LoadHistoryFromPath reads the history file at inputFilePath, returning an
+// empty history when there is none yet.
+func LoadHistoryFromPath(inputFilePath string) (History, error) {
+	inputFile, err := os.Open(inputFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return History{}, nil
+	}
+	if err != nil {
+		return History{}, err
+	}
+	defer func() { _ = inputFile.Close() }()
+	return LoadHistory(inputFile)
+}
+
+// SaveHistory writes history to outputFile.
+func SaveHistory(outputFile io.Writer, history History) error {
+	if err := json.NewEncoder(outputFile).Encode(history); err != nil {
+		return fmt.Errorf("encode history: %w", err)
+	}
+	return nil
+}
+
+// SaveHistoryToPath replaces the history file at outputFilePath with
+// history, creating its directory if needed. Concurrent invocations each
+// save a complete history, the last one winning.
+func SaveHistoryToPath(outputFilePath string, history History) error {
+	if err := os.MkdirAll(filepath.Dir(outputFilePath), 0700); err != nil {
+		return err
+	}
+	return pkg.WriteFileAtomically(outputFilePath, func(outputFile *os.File) error {
+		return SaveHistory(outputFile, history)
+	})
+}
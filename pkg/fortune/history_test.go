@@ -0,0 +1,126 @@
+package fortune
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// loadHistoryTestTree loads a fortune file holding five entries.
+func loadHistoryTestTree(t *testing.T) FileSystemNodeDescriptor {
+	t.Helper()
+	path := writeIndexedFortuneFile(t, "a\n%\nb\n%\nc\n%\nd\n%\ne\n%\n", "%", false)
+	root, err := LoadPaths([]ProbabilityPath{{Path: path}}, math.MaxUint32, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	SetProbabilities(&root, false)
+	return root
+}
+
+// TestGetUnseenRandomFortuneShuffleBag verifies that a shuffle bag shows
+// every fortune once before starting over.
+func TestGetUnseenRandomFortuneShuffleBag(t *testing.T) {
+	root := loadHistoryTestTree(t)
+	random := NewSeededRand(1)
+	window := HistoryWindow{ShuffleBag: true}
+	now := time.Now()
+
+	var history History
+	shown := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		cookie, err := GetUnseenRandomFortuneWith(root, math.MaxUint32, 0, &history, window, now, random)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if shown[cookie.Data] {
+			t.Fatalf("%q shown twice in one bag", cookie.Data)
+		}
+		shown[cookie.Data] = true
+	}
+	if _, err := GetUnseenRandomFortuneWith(root, math.MaxUint32, 0, &history, window, now, random); err != nil {
+		t.Fatalf("unexpected error once the bag is empty: %v", err)
+	}
+	if len(history.Entries) != 1 {
+		t.Errorf("expected a new bag holding 1 fortune, got %d", len(history.Entries))
+	}
+}
+
+// TestGetUnseenRandomFortuneWindow verifies that the last fortunes shown, or
+// those shown in the last days, are not picked again.
+func TestGetUnseenRandomFortuneWindow(t *testing.T) {
+	root := loadHistoryTestTree(t)
+	random := NewSeededRand(2)
+	now := time.Now()
+
+	var history History
+	var previous []string
+	for i := 0; i < 50; i++ {
+		cookie, err := GetUnseenRandomFortuneWith(root, math.MaxUint32, 0, &history, HistoryWindow{Last: 3}, now, random)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, data := range previous {
+			if data == cookie.Data {
+				t.Fatalf("%q repeated within the last 3: %q", cookie.Data, previous)
+			}
+		}
+		previous = append(previous, cookie.Data)
+		if len(previous) > 3 {
+			previous = previous[1:]
+		}
+	}
+	if len(history.Entries) != 3 {
+		t.Errorf("expected 3 fortunes in history, got %d", len(history.Entries))
+	}
+
+	path, _ := filepath.Abs(root.Children[0].Path)
+	history = History{}
+	for entry := uint32(0); entry < 4; entry++ {
+		history.Entries = append(history.Entries, HistoryEntry{Path: path, Entry: entry, Time: now.AddDate(0, 0, -int(entry))})
+	}
+	cookie, err := GetUnseenRandomFortuneWith(root, math.MaxUint32, 0, &history, HistoryWindow{Days: 3}, now, random)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cookie.Entry < 3 {
+		t.Errorf("picked entry %d shown in the last 3 days", cookie.Entry)
+	}
+}
+
+// TestGetUnseenRandomFortuneExhausted verifies that a pick is still made when
+// every fortune was seen recently.
+func TestGetUnseenRandomFortuneExhausted(t *testing.T) {
+	root := loadHistoryTestTree(t)
+	window := HistoryWindow{Last: 10}
+	var history History
+	for i := 0; i < 10; i++ {
+		if _, err := GetUnseenRandomFortuneWith(root, math.MaxUint32, 0, &history, window, time.Now(), nil); err != nil {
+			t.Fatalf("pick %d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+// TestHistoryRoundTrip verifies that a saved history loads back, and that a
+// missing history file loads as an empty history.
+func TestHistoryRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", HistoryFileName)
+	history, err := LoadHistoryFromPath(path)
+	if err != nil || len(history.Entries) != 0 {
+		t.Fatalf("expected an empty history, got %v, %v", history, err)
+	}
+
+	now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	history.Entries = []HistoryEntry{{Path: "/fortunes/a", Entry: 4, Time: now}}
+	if err := SaveHistoryToPath(path, history); err != nil {
+		t.Fatalf("save history: %v", err)
+	}
+	loaded, err := LoadHistoryFromPath(path)
+	if err != nil {
+		t.Fatalf("load history: %v", err)
+	}
+	if len(loaded.Entries) != 1 || loaded.Entries[0] != history.Entries[0] {
+		t.Errorf("expected %v, got %v", history.Entries, loaded.Entries)
+	}
+}
@@ -0,0 +1,177 @@
+package fortune
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/vromero/gofortune/pkg"
+	"github.com/vromero/gofortune/pkg/strfile"
+)
+
+// loadLeafIndex loads the index of the fortune file node describes into it,
+// from the ".dat" file next to it or, following options, built in memory.
+func loadLeafIndex(node *FileSystemNodeDescriptor, options LoadOptions) error {
+	indexPath := node.Path + ".dat"
+	if !pkg.FileExists(indexPath) {
+		if !options.AutoIndex {
+			return fmt.Errorf("%q is not a valid fortune index file", indexPath)
+		}
+		return loadInMemoryIndex(node, "%", false)
+	}
+
+	format, table, extensions, err := loadIndexFile(indexPath)
+	if err != nil {
+		return err
+	}
+	node.IndexPath, node.Format, node.Table, node.Extensions = indexPath, format, table, extensions
+
+	stale, err := isStaleIndex(*node)
+	if err != nil {
+		return err
+	}
+	if !stale {
+		return nil
+	}
+
+	staleErr := fmt.Errorf("%w: %q no longer matches %q", ErrStaleIndex, indexPath, node.Path)
+	switch options.StaleIndex {
+	case StaleIndexWarn:
+		options.warn(staleErr)
+		return nil
+	case StaleIndexRewrite:
+		if err := rewriteIndex(*node); err != nil {
+			options.warn(fmt.Errorf("%w, indexing in memory: rewrite failed: %v", staleErr, err))
+			break
+		}
+		format, table, extensions, err := loadIndexFile(indexPath)
+		if err != nil {
+			return err
+		}
+		node.Format, node.Table, node.Extensions = format, table, extensions
+		return nil
+	}
+
+	// The in-memory index keeps the conventions of the stale one so the
+	// entries are split and decoded the same way.
+	node.IndexPath = ""
+	return loadInMemoryIndex(node, pkg.IndexDelimiter(table, node.Extensions), table.Flags&pkg.FlagRotated != 0)
+}
+
+// isStaleIndex reports whether the fortune file of node changed after its
+// index file was built.
+func isStaleIndex(node FileSystemNodeDescriptor) (bool, error) {
+	indexFile, err := os.Open(node.IndexPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = indexFile.Close() }()
+	return pkg.IsStaleIndex(indexFile, node.Format, node.Table, node.Extensions, node.Path)
+}
+
+// rewriteIndex rebuilds the index file of node with strfile, keeping the
+// options recorded in its header and extensions.
+func rewriteIndex(node FileSystemNodeDescriptor) error {
+	table := node.Table
+	options := strfile.Options{
+		DelimitingChar: pkg.IndexDelimiter(table, node.Extensions),
+		Order:          table.Flags&pkg.FlagOrdered != 0,
+		Randomize:      table.Flags&pkg.FlagRandom != 0,
+		Rot13:          table.Flags&pkg.FlagRotated != 0,
+		LargeFile:      table.Version == pkg.LargeFileVersion,
+	}
+	if ordering := node.Extensions.Ordering; ordering != nil {
+		options.IgnoreCase, options.Locale = ordering.IgnoreCase, ordering.Locale
+	}
+	if seed := node.Extensions.Seed; seed != nil {
+		options.Seeded, options.Seed = true, *seed
+	}
+	options.Metadata = pkg.FileExists(pkg.MetadataIndexPath(node.IndexPath))
+	_, err := strfile.StrFile(node.Path, node.IndexPath, options)
+	return err
+}
+
+// loadInMemoryIndex indexes the fortune file of node in memory and loads the
+// result into it.
+func loadInMemoryIndex(node *FileSystemNodeDescriptor, delimitingChar string, rot13 bool) error {
+	data, err := indexInMemory(node.Path, delimitingChar, rot13)
+	if err != nil {
+		return err
+	}
+	node.IndexData = data
+	node.Format, node.Table, node.Extensions, err = loadIndex(bytes.NewReader(data), int64(len(data)), node.indexName())
+	return err
+}
+
+// indexInMemory builds the index of the fortune file at path with the same
+// logic strfile uses, without writing it to disk.
+func indexInMemory(path string, delimitingChar string, rot13 bool) ([]byte, error) {
+	inputFile, err := pkg.OpenDataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = inputFile.Close() }()
+
+	binary, err := pkg.IsBinaryFile(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if binary {
+		return nil, fmt.Errorf("%q is not a valid fortune file", path)
+	}
+
+	data, err := strfile.Index(io.NewSectionReader(inputFile, 0, math.MaxInt64), strfile.Options{DelimitingChar: delimitingChar, Rot13: rot13})
+	if err != nil {
+		return nil, fmt.Errorf("index %q: %w", path, err)
+	}
+	return data, nil
+}
+
+// loadIndexFile loads the header, layout and extensions of the index file
+// at path.
+func loadIndexFile(path string) (pkg.IndexFormat, pkg.DataTable, pkg.IndexExtensions, error) {
+	indexFile, err := os.Open(path)
+	if err != nil {
+		return pkg.IndexFormat{}, pkg.DataTable{}, pkg.IndexExtensions{}, err
+	}
+	defer func() { _ = indexFile.Close() }()
+
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return pkg.IndexFormat{}, pkg.DataTable{}, pkg.IndexExtensions{}, err
+	}
+	return loadIndex(indexFile, stat.Size(), fmt.Sprintf("%q", path))
+}
+
+// loadIndex loads the header, layout and extensions of the size bytes index
+// read from index; name describes it in errors.
+func loadIndex(index io.ReaderAt, size int64, name string) (pkg.IndexFormat, pkg.DataTable, pkg.IndexExtensions, error) {
+	// Index files written by other strfile implementations are recognised
+	// and exposed through the same table and offset API.
+	format, table, err := pkg.DetectIndexFormat(index, size)
+	if err != nil {
+		return pkg.IndexFormat{}, pkg.DataTable{}, pkg.IndexExtensions{}, fmt.Errorf("%s is not a valid fortune index file: %w", name, err)
+	}
+
+	extensions, err := pkg.LoadIndexExtensions(index, size, format, table)
+	if err != nil {
+		return pkg.IndexFormat{}, pkg.DataTable{}, pkg.IndexExtensions{}, fmt.Errorf("load index extensions from %s: %w", name, err)
+	}
+	return format, table, extensions, nil
+}
+
+// indexName describes the index of node in errors: its path or, for indexes
+// built in memory, the file they index.
+func (node FileSystemNodeDescriptor) indexName() string {
+	if node.IndexData != nil {
+		return fmt.Sprintf("in-memory index of %q", node.Path)
+	}
+	return fmt.Sprintf("%q", node.IndexPath)
+}
+
+// hasIndex reports whether node is a fortune file with a loaded index.
+func (node FileSystemNodeDescriptor) hasIndex() bool {
+	return node.IndexPath != "" || node.IndexData != nil
+}
@@ -0,0 +1,261 @@
+package fortune
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vromero/gofortune/pkg"
+	"github.com/vromero/gofortune/pkg/strfile"
+)
+
+// writeAutoIndexDir writes a directory holding a fortune file without an
+// index, next to an orphan index file and a binary file.
+func writeAutoIndexDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"fresh":       "just written\n%\nnot indexed yet\n%\n",
+		"orphan.dat":  "\x00\x00\x00\x02",
+		"image":       "\x89PNG\r\n\x1a\n\x00\x00",
+		".hidden":     "hidden\n%\n",
+		"fortunes.u8": "duplicate\n%\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestLoadPathsAutoIndex verifies that a fortune file without an index is
+// indexed in memory, while index and binary files are skipped.
+func TestLoadPathsAutoIndex(t *testing.T) {
+	dir := writeAutoIndexDir(t)
+
+	root, err := LoadPaths([]ProbabilityPath{{Path: dir}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	if root.NumFiles != 1 || root.NumEntries != 2 {
+		t.Fatalf("expected 1 file with 2 entries, got %d files with %d entries", root.NumFiles, root.NumEntries)
+	}
+	leaf := root.Children[0].Children[0]
+	if filepath.Base(leaf.Path) != "fresh" || leaf.IndexData == nil || leaf.IndexPath != "" {
+		t.Fatalf("expected in-memory index of fresh, got %+v", leaf)
+	}
+
+	cookie, err := readLeafEntry(leaf, 1)
+	if err != nil || cookie.Data != "not indexed yet" {
+		t.Errorf("expected %q, got %q (err=%v)", "not indexed yet", cookie.Data, err)
+	}
+	if !hasEntryLengths(root) {
+		t.Error("expected in-memory index to record entry lengths")
+	}
+}
+
+// TestLoadPathsSkipsEmptyFiles verifies that files holding no fortune, such
+// as a README next to a collection, are not loaded, so -e does not give them
+// a share of the odds.
+func TestLoadPathsSkipsEmptyFiles(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	if err := os.WriteFile(good, []byte("first\n%\nsecond\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strfile.StrFile(good, good+".dat", strfile.Options{}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("Install with make.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := LoadPaths([]ProbabilityPath{{Path: dir}}, 1000, 0)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	if root.NumFiles != 1 || filepath.Base(root.Children[0].Children[0].Path) != "good" {
+		t.Fatalf("expected only good to be loaded, got %+v", root.Children[0].Children)
+	}
+	SetProbabilities(&root, true)
+	for i := 0; i < 50; i++ {
+		if _, err := GetRandomFortune(root); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, err := LoadPaths([]ProbabilityPath{{Path: filepath.Join(dir, "README")}}, 1000, 0); !errors.Is(err, ErrEmptyFortuneFile) {
+		t.Errorf("expected ErrEmptyFortuneFile, got %v", err)
+	}
+}
+
+// TestLoadPathsAutoIndexDisabled verifies that without AutoIndex files lacking
+// an index are ignored, as fortune(6) does.
+func TestLoadPathsAutoIndexDisabled(t *testing.T) {
+	dir := writeAutoIndexDir(t)
+
+	root, err := LoadPathsWithOptions([]ProbabilityPath{{Path: dir}}, 1000, 0, LoadOptions{AutoIndex: false})
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	if root.NumFiles != 0 {
+		t.Errorf("expected no files, got %d", root.NumFiles)
+	}
+}
+
+// writeStaleFortuneFile indexes a fortune file and then rewrites it with
+// different contents, leaving its index out of date.
+func writeStaleFortuneFile(t *testing.T) string {
+	t.Helper()
+	path := writeIndexedFortuneFile(t, "first\n%\nsecond\n%\nthird\n%\n", "%", false)
+	if err := os.WriteFile(path, []byte("replaced\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPathsStaleIndex(t *testing.T) {
+	tests := []struct {
+		policy        StaleIndexPolicy
+		numEntries    uint64
+		inMemory      bool
+		expectWarning bool
+	}{
+		{StaleIndexReindex, 1, true, false},
+		{StaleIndexWarn, 3, false, true},
+		{StaleIndexRewrite, 1, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			path := writeStaleFortuneFile(t)
+			var warnings []error
+			options := LoadOptions{StaleIndex: test.policy, Warn: func(err error) { warnings = append(warnings, err) }}
+
+			root, err := LoadPathsWithOptions([]ProbabilityPath{{Path: path}}, 1000, 0, options)
+			if err != nil {
+				t.Fatalf("load paths: %v", err)
+			}
+			if root.NumEntries != test.numEntries {
+				t.Errorf("expected %d entries, got %d", test.numEntries, root.NumEntries)
+			}
+			leaf := root.Children[0]
+			if (leaf.IndexData != nil) != test.inMemory {
+				t.Errorf("expected in-memory index %v, got %+v", test.inMemory, leaf)
+			}
+			if (len(warnings) > 0) != test.expectWarning {
+				t.Errorf("expected warning %v, got %v", test.expectWarning, warnings)
+			}
+			for _, warning := range warnings {
+				if !errors.Is(warning, ErrStaleIndex) {
+					t.Errorf("expected ErrStaleIndex, got %v", warning)
+				}
+			}
+		})
+	}
+}
+
+// TestLoadPathsStaleRewrittenIndex verifies that a rewritten index is saved to
+// disk and no longer reported as stale.
+func TestLoadPathsStaleRewrittenIndex(t *testing.T) {
+	path := writeStaleFortuneFile(t)
+	options := LoadOptions{StaleIndex: StaleIndexRewrite}
+	if _, err := LoadPathsWithOptions([]ProbabilityPath{{Path: path}}, 1000, 0, options); err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+
+	options = LoadOptions{StaleIndex: StaleIndexWarn, Warn: func(err error) { t.Errorf("unexpected warning: %v", err) }}
+	root, err := LoadPathsWithOptions([]ProbabilityPath{{Path: path}}, 1000, 0, options)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	cookie, err := readLeafEntry(root.Children[0], 0)
+	if err != nil || cookie.Data != "replaced" {
+		t.Errorf("expected %q, got %q (err=%v)", "replaced", cookie.Data, err)
+	}
+}
+
+// TestReadStaleIndexPastEOF verifies that reading through a stale index that
+// points past the end of the file fails with a clear error.
+func TestReadStaleIndexPastEOF(t *testing.T) {
+	path := writeStaleFortuneFile(t)
+	var warned bool
+	options := LoadOptions{StaleIndex: StaleIndexWarn, Warn: func(error) { warned = true }}
+	root, err := LoadPathsWithOptions([]ProbabilityPath{{Path: path}}, 1000, 0, options)
+	if err != nil {
+		t.Fatalf("load paths: %v", err)
+	}
+	if !warned {
+		t.Error("expected a stale index warning")
+	}
+
+	_, err = readLeafEntry(root.Children[0], 2)
+	if !errors.Is(err, pkg.ErrOffsetPastEOF) || !strings.Contains(err.Error(), "strfile") {
+		t.Errorf("expected ErrOffsetPastEOF suggesting strfile, got %v", err)
+	}
+}
+
+func TestParseStaleIndexPolicy(t *testing.T) {
+	for _, policy := range []StaleIndexPolicy{StaleIndexReindex, StaleIndexWarn, StaleIndexRewrite} {
+		got, err := ParseStaleIndexPolicy(policy.String())
+		if err != nil || got != policy {
+			t.Errorf("%v: got %v (err=%v)", policy, got, err)
+		}
+	}
+	if _, err := ParseStaleIndexPolicy("ignore"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+// TestLoadPathsCompressed verifies that gzip-compressed collections are read
+// through indexes of their decompressed contents, whether built by strfile
+// or in memory.
+func TestLoadPathsCompressed(t *testing.T) {
+	const content = "first\n%\nsecond\nline\n%\nthird\n%\n"
+	for _, indexed := range []bool{true, false} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "fortunes.gz")
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		_, _ = writer.Write([]byte(content))
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if indexed {
+			if _, err := strfile.StrFile(path, path+".dat", strfile.Options{}); err != nil {
+				t.Fatalf("strfile: %v", err)
+			}
+		}
+
+		root, err := LoadPaths([]ProbabilityPath{{Path: dir}}, 1000, 0)
+		if err != nil {
+			t.Fatalf("load paths: %v", err)
+		}
+		if root.NumEntries != 3 {
+			t.Fatalf("indexed=%v: expected 3 entries, got %d", indexed, root.NumEntries)
+		}
+		leaf := root.Children[0].Children[0]
+		if indexed != (leaf.IndexPath != "") {
+			t.Errorf("indexed=%v: unexpected index path %q", indexed, leaf.IndexPath)
+		}
+
+		var got []string
+		for position := uint32(0); position < 3; position++ {
+			cookie, err := readLeafEntry(leaf, 2-position)
+			if err != nil {
+				t.Fatalf("read entry: %v", err)
+			}
+			got = append(got, cookie.Data)
+		}
+		if expected := "third|second\nline|first"; strings.Join(got, "|") != expected {
+			t.Errorf("indexed=%v: expected %q, got %q", indexed, expected, strings.Join(got, "|"))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package fortune
+
+import (
+	"errors"
+	"math/rand/v2"
+)
+
+// ErrNoFortuneMatchesLength is returned by GetLengthFilteredRandomFortune
+// when no loaded fortune satisfies the length constraints.
+var ErrNoFortuneMatchesLength = errors.New("no fortune matches the length constraints")
+
+// getExactLengthFilteredRandomFortune is the GetLengthFilteredRandomFortune
+// strategy for trees whose leaves all record their entry lengths. It draws
+// from the same distribution as repeatedly calling GetRandomFortune until the
+// length fits, without the retries: each leaf is weighted by its odds
+// times the share of its entries that fit, then one of those entries is
+// picked uniformly.
+func getExactLengthFilteredRandomFortune(rootNode FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32, random *rand.Rand) (Cookie, error) {
+	filtered, ok, _ := filterEntries(rootNode, func(leaf FileSystemNodeDescriptor) ([]uint32, error) {
+		return eligibleEntries(leaf, shorterThan, longerThan), nil
+	})
+	if !ok {
+		return Cookie{}, ErrNoFortuneMatchesLength
+	}
+	return GetRandomFortuneWith(filtered, random)
+}
+
+// hasEntryLengths reports whether every leaf under node records the length
+// of each of its entries in its index extensions.
+func hasEntryLengths(node FileSystemNodeDescriptor) bool {
+	if len(node.Children) == 0 {
+		return node.hasIndex() && node.Extensions.HasLengths(node.Table.NumberOfStrings)
+	}
+	for i := range node.Children {
+		if !hasEntryLengths(node.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// eligibleEntries returns the index positions of the selectable entries of
+// leaf whose length is in the open interval (longerThan, shorterThan).
+func eligibleEntries(leaf FileSystemNodeDescriptor, shorterThan uint32, longerThan uint32) []uint32 {
+	var positions []uint32
+	for i := 0; i < int(leaf.NumEntries); i++ {
+		position := leaf.entryPosition(i)
+		if length := leaf.Extensions.Lengths[position]; length > longerThan && length < shorterThan {
+			positions = append(positions, position)
+		}
+	}
+	return positions
+}
+
+// filterEntries returns a copy of node holding only the leaves with at least
+// one selectable entry eligible, whose Entries become the positions eligible
+// returns. Each leaf's weight is scaled by the share of its entries that
+// remain, each directory's counts become the sums of its remaining children,
+// and the Percents of the copy report the resulting chances. Returns false if
+// no leaf remains, along with the first error of eligible, which stops the
+// filtering.
+func filterEntries(node FileSystemNodeDescriptor, eligible func(leaf FileSystemNodeDescriptor) ([]uint32, error)) (FileSystemNodeDescriptor, bool, error) {
+	filtered, ok, err := filterLeaves(node, eligible)
+	if ok {
+		setPercents(&filtered)
+	}
+	return filtered, ok, err
+}
+
+// filterLeaves is filterEntries without setting the Percents.
+func filterLeaves(node FileSystemNodeDescriptor, eligible func(leaf FileSystemNodeDescriptor) ([]uint32, error)) (FileSystemNodeDescriptor, bool, error) {
+	if len(node.Children) == 0 {
+		positions, err := eligible(node)
+		if err != nil || len(positions) == 0 {
+			return FileSystemNodeDescriptor{}, false, err
+		}
+		node.weight = share(node.exactWeight(), uint64(len(positions)), node.NumEntries)
+		node.Entries = positions
+		node.NumEntries = uint64(len(positions))
+		return node, true, nil
+	}
+
+	children := make([]FileSystemNodeDescriptor, 0, len(node.Children))
+	var numEntries uint64
+	var numFiles int
+	for i := range node.Children {
+		child, ok, err := filterLeaves(node.Children[i], eligible)
+		if err != nil {
+			return FileSystemNodeDescriptor{}, false, err
+		}
+		if ok {
+			children = append(children, child)
+			numEntries += child.NumEntries
+			numFiles += child.NumFiles
+		}
+	}
+	if len(children) == 0 {
+		return FileSystemNodeDescriptor{}, false, nil
+	}
+	node.Children = children
+	node.leafTable = nil
+	node.NumEntries = numEntries
+	node.NumFiles = numFiles
+	return node, true, nil
+}
@@ -2,94 +2,255 @@ package fortune
 
 import (
 	"errors"
+	"fmt"
+	"math/big"
 	"math/rand/v2"
 )
 
-// GetRandomLeafNode walks the descriptor tree, choosing a child at each level
-// weighted by its Percent, until it reaches a leaf which it returns.
+// ErrNoProbability is returned by GetRandomLeafNode when no leaf of the tree
+// has a chance of being chosen.
+var ErrNoProbability = errors.New("no fortune file has a chance of being chosen")
+
+// SelectionStrategy tells SetProbabilitiesWithStrategy how likely each
+// fortune file is to be chosen.
+type SelectionStrategy int
+
+const (
+	// SelectBySize weights the files of a path by their number of fortunes,
+	// the files of a path with an N% weight sharing exactly N% between them
+	// and those of the other paths what the weights leave.
+	SelectBySize SelectionStrategy = iota
+	// SelectFilesEqually weights every file equally regardless of size,
+	// except that the files of a path with an N% weight share exactly N%
+	// between them, and those of the other paths what the weights leave.
+	SelectFilesEqually
+	// SelectFortunesEqually makes every fortune equally likely, except that
+	// the fortunes of a path with an N% weight share exactly N% between
+	// them, and those of the other paths what the weights leave. Files
+	// weighted by their number of fortunes give every fortune of a path the
+	// same chance, so this picks as SelectBySize does.
+	SelectFortunesEqually
+)
+
+var selectionStrategyNames = []string{"size", "equal", "fortune"}
+
+func (strategy SelectionStrategy) String() string {
+	if strategy < 0 || int(strategy) >= len(selectionStrategyNames) {
+		return fmt.Sprintf("SelectionStrategy(%d)", int(strategy))
+	}
+	return selectionStrategyNames[strategy]
+}
+
+// ParseSelectionStrategy returns the strategy called name: "size", "equal"
+// or "fortune".
+func ParseSelectionStrategy(name string) (SelectionStrategy, error) {
+	for i := range selectionStrategyNames {
+		if selectionStrategyNames[i] == name {
+			return SelectionStrategy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid selection strategy %q, expected one of %v", name, selectionStrategyNames)
+}
+
+// GetRandomLeafNode returns a leaf of the descriptor tree chosen with a
+// probability proportional to its exact odds, see SetProbabilities. The pick
+// takes constant time through the alias table SetProbabilities builds; for
+// trees without one, such as those filters return, the table is built on
+// every call.
 func GetRandomLeafNode(fsDescriptor FileSystemNodeDescriptor) (FileSystemNodeDescriptor, error) {
+	return GetRandomLeafNodeWith(fsDescriptor, nil)
+}
+
+// GetRandomLeafNodeWith is GetRandomLeafNode drawing from random; nil draws
+// from the top-level generator of math/rand/v2.
+func GetRandomLeafNodeWith(fsDescriptor FileSystemNodeDescriptor, random *rand.Rand) (FileSystemNodeDescriptor, error) {
 	if len(fsDescriptor.Children) == 0 {
 		return fsDescriptor, nil
 	}
-	r := rand.Float32() * fsDescriptor.Percent
-	var cumulativeProbability float32
-	for i := range fsDescriptor.Children {
-		cumulativeProbability += fsDescriptor.Children[i].Percent
-		if r <= cumulativeProbability {
-			return GetRandomLeafNode(fsDescriptor.Children[i])
-		}
+	table := fsDescriptor.leafTable
+	if table == nil {
+		table = newLeafTable(fsDescriptor)
 	}
-	return FileSystemNodeDescriptor{}, errors.New("no branch was randomly selected")
+	if len(table.leaves) == 0 {
+		return FileSystemNodeDescriptor{}, ErrNoProbability
+	}
+	return table.pick(randOrDefault(random)), nil
 }
 
 // SetProbabilities calculates percentage of possibility of being randomly chosen
 // for each of the nodes of a FileSystemDescriptor graph.
 func SetProbabilities(fsDescriptor *FileSystemNodeDescriptor, considerEqualSize bool) {
 	if considerEqualSize {
-		setProbabilitiesEqualSize(fsDescriptor, fsDescriptor)
+		SetProbabilitiesWithStrategy(fsDescriptor, SelectFilesEqually)
 	} else {
-		calculateUndefinedProbability(fsDescriptor)
-		setProbabilities(fsDescriptor, fsDescriptor)
+		SetProbabilitiesWithStrategy(fsDescriptor, SelectBySize)
 	}
 }
 
-// setProbabilitiesEqualSize calculates percentage of possibility of being randomly chosen
-// for each of the nodes of a FileSystemDescriptor graph assuming that each of the files
-// is of the same size.
-func setProbabilitiesEqualSize(fsDescriptor *FileSystemNodeDescriptor, fsParentDescriptor *FileSystemNodeDescriptor) float32 {
-	if len(fsDescriptor.Children) > 0 {
-		var childrenPercent float32 = 0
-		for i := range fsDescriptor.Children {
-			childrenPercent += setProbabilitiesEqualSize(&fsDescriptor.Children[i], fsParentDescriptor)
-		}
-		fsDescriptor.Percent = childrenPercent
-		return childrenPercent
-	} else {
-		filePercent := float32(1 / float64(fsParentDescriptor.NumFiles) * 100)
-		fsDescriptor.Percent = filePercent
-		return filePercent
+// SetProbabilitiesWithStrategy is SetProbabilities weighting the files as
+// strategy tells.
+//
+// The odds of each file are computed exactly, from the number of fortunes
+// of the files and the N% weights of the paths, and picks follow them
+// exactly. Percent then reports them, as the chance out of 100 of picking
+// each node. Picks no longer depend on Percent afterwards: the tree is frozen,
+// and changing its Percents or Children is not seen by GetRandomLeafNode
+// until the probabilities of a newly loaded tree are set.
+func SetProbabilitiesWithStrategy(fsDescriptor *FileSystemNodeDescriptor, strategy SelectionStrategy) {
+	calculateUndefinedProbability(fsDescriptor)
+	undefined := undefinedPercent(*fsDescriptor)
+	switch strategy {
+	case SelectFilesEqually:
+		setProbabilitiesEqualSize(fsDescriptor, undefined)
+	default:
+		setProbabilities(fsDescriptor, undefined)
 	}
+	setPercents(fsDescriptor)
+	fsDescriptor.leafTable = newLeafTable(*fsDescriptor)
 }
 
-// setProbabilities calculates percentage of possibility of being randomly chosen
-// for each of the nodes of a FileSystemDescriptor graph taking into consideration
-// the different file sizes. Returns the total percentage assigned to the children.
-func setProbabilities(fsDescriptor *FileSystemNodeDescriptor, fsParentDescriptor *FileSystemNodeDescriptor) float32 {
-	if len(fsDescriptor.Children) > 0 {
-		var childrenPercent float32 = 0
-		for i := range fsDescriptor.Children {
-			childrenPercent += setProbabilities(&fsDescriptor.Children[i], fsParentDescriptor)
+// FortuneProbability returns the percentage of possibility of each fortune
+// of the leaf node being randomly chosen.
+func (node FileSystemNodeDescriptor) FortuneProbability() float32 {
+	if node.NumEntries == 0 {
+		return 0
+	}
+	return float32(float64(node.Percent) / float64(node.NumEntries))
+}
+
+// exactWeight returns the odds of the leaf node relative to the other leaves
+// of its tree: those SetProbabilities gave it, or its Percent for trees it
+// did not weigh. The result must not be modified.
+func (node FileSystemNodeDescriptor) exactWeight() *big.Rat {
+	if node.weight != nil {
+		return node.weight
+	}
+	return exactPercent(node.Percent)
+}
+
+// exactPercent returns percent as a fraction, or zero unless it is positive.
+// A float32 is a binary fraction, which big.Rat holds exactly.
+func exactPercent(percent float32) *big.Rat {
+	if !(percent > 0) {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFloat64(float64(percent))
+}
+
+// share returns the part of percent that part makes of whole, or zero when
+// whole is.
+func share(percent *big.Rat, part uint64, whole uint64) *big.Rat {
+	if whole == 0 {
+		return new(big.Rat)
+	}
+	weight := new(big.Rat).SetFrac(new(big.Int).SetUint64(part), new(big.Int).SetUint64(whole))
+	return weight.Mul(weight, percent)
+}
+
+// undefinedPercent returns the percentage the children of rootFsDescriptor
+// without a user-defined one share: what those with one leave of 100.
+func undefinedPercent(rootFsDescriptor FileSystemNodeDescriptor) *big.Rat {
+	undefined := big.NewRat(100, 1)
+	for i := range rootFsDescriptor.Children {
+		undefined.Sub(undefined, exactPercent(rootFsDescriptor.Children[i].Percent))
+	}
+	return undefined
+}
+
+// setPercents sets the Percent of every node under fsDescriptor, itself
+// included, to its chance out of 100 of being picked, from the exact weights
+// of the leaves. Leaves without a positive weight get 0.
+func setPercents(fsDescriptor *FileSystemNodeDescriptor) {
+	total := new(big.Rat)
+	walkLeaves(*fsDescriptor, func(leaf FileSystemNodeDescriptor) {
+		if weight := leaf.exactWeight(); weight.Sign() > 0 {
+			total.Add(total, weight)
 		}
-		fsDescriptor.Percent = childrenPercent
-		return childrenPercent
-	} else {
-		if fsDescriptor.Percent == 0 {
-			distributableAmount, distributablePercent := findDistributableAmountAndProbability(fsDescriptor, fsParentDescriptor)
-			filePercent := float32(float64(fsDescriptor.Table.NumberOfStrings) / float64(distributableAmount) * float64(distributablePercent))
-			fsDescriptor.Percent = filePercent
-			return filePercent
-		} else {
-			return fsDescriptor.Percent
+	})
+	setPercentsOf(fsDescriptor, total)
+}
+
+// setPercentsOf is setPercents for leaves weighing total in all. Returns the
+// weight of fsDescriptor.
+func setPercentsOf(fsDescriptor *FileSystemNodeDescriptor, total *big.Rat) *big.Rat {
+	weight := new(big.Rat)
+	if len(fsDescriptor.Children) == 0 {
+		if leafWeight := fsDescriptor.exactWeight(); leafWeight.Sign() > 0 {
+			weight.Set(leafWeight)
 		}
 	}
+	for i := range fsDescriptor.Children {
+		weight.Add(weight, setPercentsOf(&fsDescriptor.Children[i], total))
+	}
+	fsDescriptor.Percent = 0
+	if total.Sign() > 0 {
+		percent := new(big.Rat).Mul(weight, big.NewRat(100, 1))
+		fsDescriptor.Percent, _ = percent.Quo(percent, total).Float32()
+	}
+	return weight
 }
 
-func findDistributableAmountAndProbability(fsDescriptor *FileSystemNodeDescriptor, fsParentDescriptor *FileSystemNodeDescriptor) (uint64, float32) {
-	current := fsDescriptor
-	for {
-		if current.Percent > 0 {
-			return fsParentDescriptor.NumEntries - fsParentDescriptor.UndefinedNumEntries, current.Percent
-		}
+// setProbabilitiesEqualSize calculates percentage of possibility of being
+// randomly chosen for each of the nodes of a FileSystemDescriptor graph
+// assuming that each of the files is of the same size: the files of each path
+// with a user-defined percentage share it equally, and those of the other
+// paths share equally the undefined percentage that remains.
+func setProbabilitiesEqualSize(rootFsDescriptor *FileSystemNodeDescriptor, undefined *big.Rat) {
+	spreadProbabilities(rootFsDescriptor, undefined, countFiles)
+}
 
-		current = current.Parent
-		if current == nil ||
-			current.Parent == nil { // We do not want to process the parent as it always has 100% and that is not used defined
-			break
+// countFiles returns the number of leaves under node.
+func countFiles(node FileSystemNodeDescriptor) uint64 {
+	var files uint64
+	walkLeaves(node, func(FileSystemNodeDescriptor) {
+		files++
+	})
+	return files
+}
+
+// spreadProbabilities gives the files of each path under rootFsDescriptor
+// with a user-defined percentage the share of it their size makes of the
+// size of the path, and those of the other paths the share of the undefined
+// percentage their size makes of the size of all of them.
+func spreadProbabilities(rootFsDescriptor *FileSystemNodeDescriptor, undefined *big.Rat, size func(node FileSystemNodeDescriptor) uint64) {
+	var undefinedSize uint64
+	for i := range rootFsDescriptor.Children {
+		if rootFsDescriptor.Children[i].Percent <= 0 {
+			undefinedSize += size(rootFsDescriptor.Children[i])
+		}
+	}
+	for i := range rootFsDescriptor.Children {
+		child := &rootFsDescriptor.Children[i]
+		if child.Percent > 0 {
+			spreadProbability(child, exactPercent(child.Percent), size(*child), size)
+		} else {
+			spreadProbability(child, undefined, undefinedSize, size)
 		}
 	}
+}
 
-	// If we reach this point, means that we should use the undefined percentage
-	return fsParentDescriptor.UndefinedNumEntries, fsParentDescriptor.UndefinedChildrenPercent
+// spreadProbability gives each file under fsDescriptor the share of percent
+// its size makes of total.
+func spreadProbability(fsDescriptor *FileSystemNodeDescriptor, percent *big.Rat, total uint64, size func(node FileSystemNodeDescriptor) uint64) {
+	for i := range fsDescriptor.Children {
+		spreadProbability(&fsDescriptor.Children[i], percent, total, size)
+	}
+	if len(fsDescriptor.Children) == 0 {
+		fsDescriptor.weight = share(percent, size(*fsDescriptor), total)
+	}
+}
+
+// setProbabilities calculates percentage of possibility of being randomly chosen
+// for each of the nodes of a FileSystemDescriptor graph taking into consideration
+// the different file sizes: the files of each path with a user-defined
+// percentage share it by their number of fortunes, and those of the other
+// paths share the undefined percentage that remains the same way. Every
+// fortune of a path is then equally likely, so SelectFortunesEqually is
+// served by it too.
+func setProbabilities(rootFsDescriptor *FileSystemNodeDescriptor, undefined *big.Rat) {
+	spreadProbabilities(rootFsDescriptor, undefined, func(node FileSystemNodeDescriptor) uint64 {
+		return node.NumEntries
+	})
 }
 
 func calculateUndefinedProbability(rootFsDescriptor *FileSystemNodeDescriptor) {
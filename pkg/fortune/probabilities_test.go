@@ -1,6 +1,7 @@
 package fortune
 
 import (
+	"math"
 	"strconv"
 	"testing"
 )
@@ -38,3 +39,72 @@ func TestCalculateUndefinedPercents(t *testing.T) {
 		t.Error("UndefinedNumEntries expected 40 got " + strconv.Itoa(int(root.UndefinedNumEntries)))
 	}
 }
+
+// TestSetProbabilitiesPerFortune verifies that every fortune of the paths
+// without a weight is equally likely, and that the fortunes of a weighted
+// path share its weight.
+func TestSetProbabilitiesPerFortune(t *testing.T) {
+	root := FileSystemNodeDescriptor{
+		Percent: 100,
+		Children: []FileSystemNodeDescriptor{
+			{
+				NumEntries: 40,
+				Children:   []FileSystemNodeDescriptor{{NumEntries: 10}, {NumEntries: 30}},
+			},
+			{Percent: 50, NumEntries: 5},
+			{NumEntries: 60},
+		},
+	}
+	SetProbabilitiesWithStrategy(&root, SelectFortunesEqually)
+
+	leaves := []FileSystemNodeDescriptor{root.Children[0].Children[0], root.Children[0].Children[1], root.Children[1], root.Children[2]}
+	for i, want := range []float32{0.5, 0.5, 10, 0.5} {
+		if got := leaves[i].FortuneProbability(); math.Abs(float64(got-want)) > 1e-4 {
+			t.Errorf("leaf %d: expected %v%% per fortune, got %v%%", i, want, got)
+		}
+	}
+	if root.Children[0].Percent != 20 || math.Abs(float64(root.Percent-100)) > 1e-4 {
+		t.Errorf("expected 20%% for the directory and 100%% in total, got %v%% and %v%%", root.Children[0].Percent, root.Percent)
+	}
+}
+
+func TestParseSelectionStrategy(t *testing.T) {
+	for _, strategy := range []SelectionStrategy{SelectBySize, SelectFilesEqually, SelectFortunesEqually} {
+		got, err := ParseSelectionStrategy(strategy.String())
+		if err != nil || got != strategy {
+			t.Errorf("%v: got %v (err=%v)", strategy, got, err)
+		}
+	}
+	if _, err := ParseSelectionStrategy("file"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+// TestSetProbabilitiesEqualSizeWeighted verifies that the files of a path
+// with a weight share it equally, and those of the other paths share equally
+// what the weight leaves.
+func TestSetProbabilitiesEqualSizeWeighted(t *testing.T) {
+	root := FileSystemNodeDescriptor{
+		Percent: 100,
+		Children: []FileSystemNodeDescriptor{
+			{
+				Percent:    50,
+				NumEntries: 40,
+				Children:   []FileSystemNodeDescriptor{{NumEntries: 10}, {NumEntries: 30}},
+			},
+			{NumEntries: 5},
+			{NumEntries: 60},
+		},
+	}
+	SetProbabilitiesWithStrategy(&root, SelectFilesEqually)
+
+	leaves := []FileSystemNodeDescriptor{root.Children[0].Children[0], root.Children[0].Children[1], root.Children[1], root.Children[2]}
+	for i := range leaves {
+		if leaves[i].Percent != 25 {
+			t.Errorf("leaf %d: expected 25%%, got %v%%", i, leaves[i].Percent)
+		}
+	}
+	if root.Children[0].Percent != 50 || root.Percent != 100 {
+		t.Errorf("expected 50%% for the weighted path and 100%% in total, got %v%% and %v%%", root.Children[0].Percent, root.Percent)
+	}
+}
@@ -0,0 +1,48 @@
+package fortune
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"math/rand/v2"
+)
+
+// globalSource draws from the top-level generator of math/rand/v2, which is
+// safe for concurrent use.
+type globalSource struct{}
+
+func (globalSource) Uint64() uint64 {
+	return rand.Uint64()
+}
+
+// cryptoSource draws from crypto/rand.
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var buffer [8]byte
+	_, _ = cryptorand.Read(buffer[:])
+	return binary.LittleEndian.Uint64(buffer[:])
+}
+
+// defaultRand is what the selection functions draw from when given a nil
+// *rand.Rand.
+var defaultRand = rand.New(globalSource{})
+
+// NewSeededRand returns a generator that always yields the same sequence for
+// the same seed, so selections made with it can be reproduced.
+func NewSeededRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+// NewCryptoRand returns a generator drawing from crypto/rand, whose picks
+// cannot be predicted from earlier ones. It is safe for concurrent use.
+func NewCryptoRand() *rand.Rand {
+	return rand.New(cryptoSource{})
+}
+
+// randOrDefault returns random, or defaultRand when nil.
+func randOrDefault(random *rand.Rand) *rand.Rand {
+	if random == nil {
+		return defaultRand
+	}
+	return random
+}
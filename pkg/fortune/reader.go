@@ -0,0 +1,111 @@
+package fortune
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// leafReader gives positional access to the entries of a leaf fortune file
+// through its index, applying the conventions recorded in the index header
+// (delimiter, rot13) so every read path presents entries the same way.
+type leafReader struct {
+	node        FileSystemNodeDescriptor
+	index       io.ReaderAt
+	indexFile   *os.File // nil for indexes built in memory
+	fortuneFile pkg.DataFile
+}
+
+// openLeaf opens the index and fortune files of node. The caller must Close
+// the returned reader.
+func openLeaf(node FileSystemNodeDescriptor) (*leafReader, error) {
+	reader := &leafReader{node: node}
+	if node.IndexData != nil {
+		reader.index = bytes.NewReader(node.IndexData)
+	} else {
+		indexFile, err := os.Open(node.IndexPath)
+		if err != nil {
+			return nil, fmt.Errorf("open index file %q: %w", node.IndexPath, err)
+		}
+		reader.index, reader.indexFile = indexFile, indexFile
+	}
+
+	fortuneFile, err := pkg.OpenDataFile(node.Path)
+	if err != nil {
+		_ = reader.Close()
+		return nil, fmt.Errorf("open fortune file %q: %w", node.Path, err)
+	}
+	reader.fortuneFile = fortuneFile
+
+	return reader, nil
+}
+
+// Close closes the underlying index and fortune files.
+func (r *leafReader) Close() error {
+	var ierr, ferr error
+	if r.indexFile != nil {
+		ierr = r.indexFile.Close()
+	}
+	if r.fortuneFile != nil {
+		ferr = r.fortuneFile.Close()
+	}
+	if ierr != nil {
+		return ierr
+	}
+	return ferr
+}
+
+// entry reads the entry listed at position of the index.
+func (r *leafReader) entry(position uint32) (Cookie, error) {
+	dataPos, err := r.node.Format.ReadDataPos(r.index, position)
+	if err != nil {
+		return Cookie{}, fmt.Errorf("read index %s entry %d: %w", r.node.indexName(), position, err)
+	}
+
+	end := r.node.Format.ReadDataEnd(r.index, r.node.Table, position)
+	data, err := pkg.ReadData(r.fortuneFile, int64(dataPos.OriginalOffset), end, pkg.IndexDelimiter(r.node.Table, r.node.Extensions))
+	if errors.Is(err, pkg.ErrOffsetPastEOF) {
+		return Cookie{}, fmt.Errorf("read fortune file %q entry %d: %w (the file changed after %s was built; rebuild it with strfile)",
+			r.node.Path, position, err, r.node.indexName())
+	}
+	if err != nil {
+		return Cookie{}, fmt.Errorf("read fortune file %q entry %d: %w", r.node.Path, position, err)
+	}
+	return newCookie(r.node, position, data), nil
+}
+
+// readLeafEntry reads the single entry listed at position of node's index.
+func readLeafEntry(node FileSystemNodeDescriptor, position uint32) (Cookie, error) {
+	reader, err := openLeaf(node)
+	if err != nil {
+		return Cookie{}, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	return reader.entry(position)
+}
+
+// newCookie builds the Cookie for the entry at position of node, decoding it
+// when the node's index marks the collection as rot13'd.
+func newCookie(node FileSystemNodeDescriptor, position uint32, data string) Cookie {
+	rotated := node.Table.Flags&pkg.FlagRotated != 0
+	if rotated {
+		data = pkg.Rot13(data)
+	}
+	attribution, _ := pkg.ParseAttribution(data)
+	return Cookie{FileName: filepath.Base(node.Path), Path: node.Path, Entry: position, Data: data, Rotated: rotated, Attribution: attribution}
+}
+
+// entryPosition returns the index position of the i-th selectable entry of
+// the leaf node.
+func (node FileSystemNodeDescriptor) entryPosition(i int) uint32 {
+	if node.Entries != nil {
+		return node.Entries[i]
+	}
+	return uint32(i)
+}
@@ -0,0 +1,131 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// LockFile takes an exclusive advisory lock on the file at path, creating it
+// if needed, and blocks until the lock is granted. The lock is released by
+// calling the returned function, which also removes the file so that no lock
+// file is left behind. Processes that do not lock the file are not kept from
+// using it; on platforms without file locking LockFile does not block at all.
+func LockFile(path string) (unlock func() error, err error) {
+	for {
+		lockFile, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFileHandle(lockFile); err != nil {
+			_ = lockFile.Close()
+			return nil, fmt.Errorf("lock %q: %w", path, err)
+		}
+		// The previous holder may have removed the file while we waited
+		// for it, leaving us the lock of a file no one else will open.
+		if isFileAt(lockFile, path) {
+			return func() error {
+				return releaseLockFile(lockFile, path)
+			}, nil
+		}
+		_ = unlockFileHandle(lockFile)
+		_ = lockFile.Close()
+	}
+}
+
+// isFileAt reports whether file is still the file found at path.
+func isFileAt(file *os.File, path string) bool {
+	stat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	pathStat, err := os.Stat(path)
+	return err == nil && os.SameFile(stat, pathStat)
+}
+
+// releaseLockFile removes and unlocks the lock file taken by LockFile.
+func releaseLockFile(lockFile *os.File, path string) error {
+	// The file is removed while still locked, so that whoever opens the
+	// path next creates a new file rather than locking one about to go.
+	_ = os.Remove(path)
+	uerr := unlockFileHandle(lockFile)
+	if cerr := lockFile.Close(); uerr == nil {
+		uerr = cerr
+	}
+	if runtime.GOOS == "windows" {
+		// Windows refuses to remove open files. Once closed, the file can
+		// only be removed if no one else opened it meanwhile.
+		_ = os.Remove(path)
+	}
+	return uerr
+}
+
+// WriteFileAtomically creates or replaces the file at path with what write
+// writes to outputFile. The contents are written to a temporary file in the
+// same directory, synced and renamed over path, so readers see either the
+// previous file or the complete new one, even after a crash. Concurrent calls
+// for the same path, from this or other processes, are serialised through an
+// advisory lock on a hidden ".name.lock" file next to it, which is removed
+// again when done.
+func WriteFileAtomically(path string, write func(outputFile *os.File) error) (err error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	unlock, err := LockFile(filepath.Join(dir, "."+name+".lock"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+
+	// The result keeps the permissions of the file it replaces. New files get
+	// those os.Create would, 0666 less the umask, by creating the temporary
+	// file with them; the lock keeps its name to this call.
+	perm, replacing := os.FileMode(0666), false
+	if stat, serr := os.Stat(path); serr == nil {
+		perm, replacing = stat.Mode().Perm(), true
+	}
+	tempPath := filepath.Join(dir, "."+name+".tmp")
+	// A crash may have left one behind, with other permissions.
+	if err := os.Remove(tempPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	outputFile, err := os.OpenFile(tempPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = outputFile.Close()
+			_ = os.Remove(outputFile.Name())
+		}
+	}()
+
+	if err := write(outputFile); err != nil {
+		return err
+	}
+	if replacing {
+		// The umask may have taken permissions away.
+		if err := outputFile.Chmod(perm); err != nil {
+			return err
+		}
+	}
+	if err := outputFile.Sync(); err != nil {
+		return fmt.Errorf("sync %q: %w", outputFile.Name(), err)
+	}
+	if err := outputFile.Close(); err != nil {
+		return fmt.Errorf("close %q: %w", outputFile.Name(), err)
+	}
+	if err := os.Rename(outputFile.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris || windows)
+
+package pkg
+
+import "os"
+
+// Advisory locks are not available on this platform.
+
+func lockFileHandle(*os.File) error {
+	return nil
+}
+
+func unlockFileHandle(*os.File) error {
+	return nil
+}
+
+func syncDir(string) error {
+	return nil
+}
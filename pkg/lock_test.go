@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fortunes.dat")
+	if err := os.WriteFile(path, []byte("old"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	// Whatever the umask took away.
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed write leaves the previous file and no temporary file behind.
+	failure := errors.New("write failed")
+	err := WriteFileAtomically(path, func(outputFile *os.File) error {
+		_, _ = outputFile.WriteString("partial")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("expected the previous contents, got %q", data)
+	}
+
+	err = WriteFileAtomically(path, func(outputFile *os.File) error {
+		_, err := outputFile.WriteString("new")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("expected the new contents, got %q", data)
+	}
+	// Windows only tracks the read-only bit.
+	if stat, err := os.Stat(path); err != nil || runtime.GOOS != "windows" && stat.Mode().Perm() != 0640 {
+		t.Errorf("expected the previous permissions to be kept, got %v (err=%v)", stat.Mode(), err)
+	}
+
+	// Neither temporary nor lock files are left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "fortunes.dat" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("expected only fortunes.dat, got %q", names)
+	}
+}
+
+// TestLockFileSerialises verifies that holders of the lock on the same file
+// never overlap.
+// TestWriteFileAtomicallyNewFileMode verifies that new files get the
+// permissions os.Create gives, which honour the umask.
+func TestWriteFileAtomicallyNewFileMode(t *testing.T) {
+	dir := t.TempDir()
+	created, err := os.Create(filepath.Join(dir, "created"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := created.Stat()
+	_ = created.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "history.json")
+	if err := WriteFileAtomically(path, func(outputFile *os.File) error { return nil }); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if stat, err := os.Stat(path); err != nil || stat.Mode().Perm() != want.Mode().Perm() {
+		t.Errorf("expected permissions %v, got %v (err=%v)", want.Mode().Perm(), stat.Mode().Perm(), err)
+	}
+}
+
+func TestLockFileSerialises(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		holders int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := LockFile(path)
+			if err != nil {
+				t.Errorf("lock: %v", err)
+				return
+			}
+			mutex.Lock()
+			holders++
+			if holders > 1 {
+				t.Error("lock held twice at once")
+			}
+			mutex.Unlock()
+
+			time.Sleep(time.Millisecond)
+			mutex.Lock()
+			holders--
+			mutex.Unlock()
+			if err := unlock(); err != nil {
+				t.Errorf("unlock: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the lock file to be removed, got %v", err)
+	}
+}
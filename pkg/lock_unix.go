@@ -0,0 +1,27 @@
+//go:build darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+
+package pkg
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func lockFileHandle(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_EX)
+}
+
+func unlockFileHandle(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
+
+// syncDir flushes the directory entry of a file renamed into dir.
+func syncDir(dir string) error {
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dirFile.Close() }()
+	return dirFile.Sync()
+}
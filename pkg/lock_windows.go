@@ -0,0 +1,23 @@
+//go:build windows
+
+package pkg
+
+import (
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func lockFileHandle(file *os.File) error {
+	return windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, math.MaxUint32, math.MaxUint32, new(windows.Overlapped))
+}
+
+func unlockFileHandle(file *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, math.MaxUint32, math.MaxUint32, new(windows.Overlapped))
+}
+
+// syncDir is a no-op: Windows offers no way to sync a directory.
+func syncDir(string) error {
+	return nil
+}
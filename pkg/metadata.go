@@ -0,0 +1,184 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// MetadataMagic starts every metadata index file.
+const MetadataMagic = "GFMD"
+
+// MetadataVersion is the version of the metadata index format.
+const MetadataVersion = 1
+
+// MetadataSuffix replaces the ".dat" suffix of an index to name its metadata
+// index.
+const MetadataSuffix = ".meta"
+
+// ErrCorruptMetadata is returned when a metadata index cannot be decoded.
+var ErrCorruptMetadata = errors.New("corrupt metadata index")
+
+// MetadataIndex is the sidecar of an index listing the Attribution of each
+// of its entries, so fortunes can be selected by author or source without
+// reading their text.
+//
+// The file holds a header with the number of entries and the SHA-256 of the
+// data file they come from, a table of the distinct names found, each as a
+// length-prefixed string, and one record per entry, in the order the offset
+// table lists them, pointing into the name table. Every number is big-endian.
+type MetadataIndex struct {
+	// SourceSHA256 is the SHA-256 recorded in the fingerprint of the index
+	// described, or zero.
+	SourceSHA256 [sha256.Size]byte
+	// Attributions holds the attribution of each entry of the index, in
+	// table order.
+	Attributions []Attribution
+}
+
+type metadataHeader struct {
+	Magic           [4]byte
+	Version         uint32
+	NumberOfStrings uint32
+	SourceSHA256    [sha256.Size]byte
+	NumberOfNames   uint32
+}
+
+// metadataRecord refers to names by their position in the name table plus
+// one, zero meaning none.
+type metadataRecord struct {
+	Author uint32
+	Source uint32
+	Year   int32
+}
+
+// MetadataIndexPath returns the path of the metadata index of the index
+// dataFile: "fortunes.dat" is described by "fortunes.meta".
+func MetadataIndexPath(dataFile string) string {
+	return strings.TrimSuffix(dataFile, ".dat") + MetadataSuffix
+}
+
+// Matches reports whether metadata describes the index with header table and
+// extensions, rather than an earlier build of it.
+func (metadata MetadataIndex) Matches(table DataTable, extensions IndexExtensions) bool {
+	if len(metadata.Attributions) != int(table.NumberOfStrings) {
+		return false
+	}
+	return extensions.Fingerprint == nil || extensions.Fingerprint.SHA256 == metadata.SourceSHA256
+}
+
+// SaveMetadataIndex writes metadata to outputFile.
+func SaveMetadataIndex(outputFile io.Writer, metadata MetadataIndex) error {
+	var names []string
+	ids := make(map[string]uint32)
+	id := func(name string) uint32 {
+		if name == "" {
+			return 0
+		}
+		if _, ok := ids[name]; !ok {
+			names = append(names, name)
+			ids[name] = uint32(len(names))
+		}
+		return ids[name]
+	}
+	records := make([]metadataRecord, len(metadata.Attributions))
+	for i, attribution := range metadata.Attributions {
+		records[i] = metadataRecord{Author: id(attribution.Author), Source: id(attribution.Source), Year: int32(attribution.Year)}
+	}
+
+	writer := bufio.NewWriter(outputFile)
+	header := metadataHeader{
+		Version:         MetadataVersion,
+		NumberOfStrings: uint32(len(records)),
+		SourceSHA256:    metadata.SourceSHA256,
+		NumberOfNames:   uint32(len(names)),
+	}
+	copy(header.Magic[:], MetadataMagic)
+	if err := binary.Write(writer, binary.BigEndian, header); err != nil {
+		return fmt.Errorf("write metadata header: %w", err)
+	}
+	for _, name := range names {
+		if len(name) > math.MaxUint16 {
+			name = name[:math.MaxUint16]
+		}
+		if err := binary.Write(writer, binary.BigEndian, uint16(len(name))); err != nil {
+			return fmt.Errorf("write metadata names: %w", err)
+		}
+		if _, err := writer.WriteString(name); err != nil {
+			return fmt.Errorf("write metadata names: %w", err)
+		}
+	}
+	if err := binary.Write(writer, binary.BigEndian, records); err != nil {
+		return fmt.Errorf("write metadata records: %w", err)
+	}
+	return writer.Flush()
+}
+
+func LoadMetadataIndexFromPath(inputFilePath string) (MetadataIndex, error) {
+	inputFile, err := os.Open(inputFilePath)
+	if err != nil {
+		return MetadataIndex{}, err
+	}
+	defer func() { _ = inputFile.Close() }()
+	return LoadMetadataIndex(inputFile)
+}
+
+// LoadMetadataIndex reads a metadata index written by SaveMetadataIndex.
+func LoadMetadataIndex(inputFile io.Reader) (MetadataIndex, error) {
+	reader := bufio.NewReader(inputFile)
+	var header metadataHeader
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return MetadataIndex{}, fmt.Errorf("%w: header: %v", ErrCorruptMetadata, err)
+	}
+	if string(header.Magic[:]) != MetadataMagic || header.Version != MetadataVersion {
+		return MetadataIndex{}, fmt.Errorf("%w: unsupported format", ErrCorruptMetadata)
+	}
+
+	var names []string
+	for i := uint32(0); i < header.NumberOfNames; i++ {
+		var length uint16
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return MetadataIndex{}, fmt.Errorf("%w: names: %v", ErrCorruptMetadata, err)
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(reader, name); err != nil {
+			return MetadataIndex{}, fmt.Errorf("%w: names: %v", ErrCorruptMetadata, err)
+		}
+		names = append(names, string(name))
+	}
+	name := func(id uint32) (string, error) {
+		if id > uint32(len(names)) {
+			return "", fmt.Errorf("%w: name %d out of range", ErrCorruptMetadata, id)
+		}
+		if id == 0 {
+			return "", nil
+		}
+		return names[id-1], nil
+	}
+
+	// Attributions are appended as read so a corrupt count cannot allocate
+	// more than the file holds.
+	metadata := MetadataIndex{SourceSHA256: header.SourceSHA256, Attributions: []Attribution{}}
+	for i := uint32(0); i < header.NumberOfStrings; i++ {
+		var record metadataRecord
+		if err := binary.Read(reader, binary.BigEndian, &record); err != nil {
+			return MetadataIndex{}, fmt.Errorf("%w: record %d: %v", ErrCorruptMetadata, i, err)
+		}
+		author, err := name(record.Author)
+		if err != nil {
+			return MetadataIndex{}, err
+		}
+		source, err := name(record.Source)
+		if err != nil {
+			return MetadataIndex{}, err
+		}
+		metadata.Attributions = append(metadata.Attributions, Attribution{Author: author, Source: source, Year: int(record.Year)})
+	}
+	return metadata, nil
+}
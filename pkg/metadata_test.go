@@ -0,0 +1,52 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMetadataIndexRoundTrip(t *testing.T) {
+	metadata := MetadataIndex{
+		SourceSHA256: [32]byte{1, 2, 3},
+		Attributions: []Attribution{
+			{Author: "Mark Twain", Source: "Following the Equator", Year: 1897},
+			{},
+			{Author: "Mark Twain"},
+			{Source: "Proverbs"},
+		},
+	}
+	var buffer bytes.Buffer
+	if err := SaveMetadataIndex(&buffer, metadata); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := LoadMetadataIndex(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !reflect.DeepEqual(got, metadata) {
+		t.Errorf("expected %+v, got %+v", metadata, got)
+	}
+
+	table := DataTable{NumberOfStrings: 4}
+	if !got.Matches(table, IndexExtensions{Fingerprint: &SourceFingerprint{SHA256: [32]byte{1, 2, 3}}}) {
+		t.Error("expected the metadata to match its index")
+	}
+	if got.Matches(table, IndexExtensions{Fingerprint: &SourceFingerprint{SHA256: [32]byte{4}}}) {
+		t.Error("expected the metadata not to match a rebuilt index")
+	}
+
+	if _, err := LoadMetadataIndex(bytes.NewReader(buffer.Bytes()[:buffer.Len()-1])); !errors.Is(err, ErrCorruptMetadata) {
+		t.Errorf("expected ErrCorruptMetadata for a truncated file, got %v", err)
+	}
+}
+
+func TestMetadataIndexPath(t *testing.T) {
+	if got := MetadataIndexPath("/usr/share/games/fortunes/art.dat"); got != "/usr/share/games/fortunes/art.meta" {
+		t.Errorf("unexpected path %q", got)
+	}
+	if !IsIgnoredFileName("art.meta") {
+		t.Error("expected metadata indexes to be ignored as fortune files")
+	}
+}
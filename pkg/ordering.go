@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Ordering describes how the entries of an ordered index were sorted, so
+// they can be checked against the same rules.
+type Ordering struct {
+	// IgnoreCase folds case before comparing entries.
+	IgnoreCase bool
+	// Locale is the BCP 47 tag of the language whose collation sorted the
+	// entries; empty for code point order.
+	Locale string
+}
+
+// orderingIgnoreCase is the bit of the first byte of an encoded Ordering
+// recording IgnoreCase; the locale tag follows that byte.
+const orderingIgnoreCase = 1
+
+// MarshalBinary encodes ordering for the index extensions.
+func (ordering Ordering) MarshalBinary() ([]byte, error) {
+	var flags byte
+	if ordering.IgnoreCase {
+		flags |= orderingIgnoreCase
+	}
+	return append([]byte{flags}, ordering.Locale...), nil
+}
+
+// UnmarshalBinary decodes an ordering encoded by MarshalBinary.
+func (ordering *Ordering) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return ErrCorruptExtension
+	}
+	ordering.IgnoreCase = data[0]&orderingIgnoreCase != 0
+	ordering.Locale = string(data[1:])
+	return nil
+}
+
+// OrderingKey returns the part of text entries are ordered by: like the
+// classic strfile, leading characters that are neither letters nor digits,
+// such as quotes and dashes, are ignored.
+func OrderingKey(text string) string {
+	return strings.TrimLeftFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
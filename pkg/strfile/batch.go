@@ -0,0 +1,222 @@
+package strfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// BatchOptions configures StrFileTree. Options apply to every file.
+type BatchOptions struct {
+	Options
+	// Workers bounds how many indexes are built at once; zero or less means
+	// one per CPU.
+	Workers int
+}
+
+// BatchFailure pairs a file StrFileTree could not index with the reason.
+type BatchFailure struct {
+	SourceFile string
+	Err        error
+}
+
+// BatchSummary describes the result of StrFileTree. Every list is sorted by
+// path.
+type BatchSummary struct {
+	Indexed  []Summary      // Indexes built, because they were missing or stale
+	UpToDate []string       // Files whose index already matched them
+	Skipped  []string       // Binary and other non-regular files
+	Failed   []BatchFailure // Files that could not be indexed
+}
+
+// Total aggregates the strings of every index built into a single Summary.
+func (s BatchSummary) Total() Summary {
+	total := Summary{ShortestFortune: math.MaxUint32}
+	for _, summary := range s.Indexed {
+		if summary.TotalFortunes == 0 {
+			continue
+		}
+		total.TotalFortunes += summary.TotalFortunes
+		total.LongestFortune = pkg.Max(total.LongestFortune, summary.LongestFortune)
+		total.ShortestFortune = pkg.Min(total.ShortestFortune, summary.ShortestFortune)
+	}
+	return total
+}
+
+// Err returns the errors of every failed file joined, or nil when all files
+// were indexed.
+func (s BatchSummary) Err() error {
+	errs := make([]error, 0, len(s.Failed))
+	for _, failure := range s.Failed {
+		errs = append(errs, fmt.Errorf("%q: %w", failure.SourceFile, failure.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// WriteTo writes a human-readable report of s to w: how many files were
+// indexed, up to date, skipped or failed, the aggregated strings of the
+// indexes built and the reason of every failure.
+func (s BatchSummary) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	write := func(format string, args ...any) error {
+		n, err := fmt.Fprintf(w, format, args...)
+		total += int64(n)
+		return err
+	}
+
+	if err := write("%d created, %d up to date, %d skipped, %d failed\n",
+		len(s.Indexed), len(s.UpToDate), len(s.Skipped), len(s.Failed)); err != nil {
+		return total, err
+	}
+	if err := s.Total().writeStrings(write); err != nil {
+		return total, err
+	}
+	for _, failure := range s.Failed {
+		if err := write("%q failed: %v\n", failure.SourceFile, failure.Err); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// StrFileTree indexes every fortune file under root whose index is missing or
+// out of date, building up to options.Workers indexes concurrently. Each
+// index is written next to its file with a ".dat" suffix appended, where
+// fortune looks for it. Hidden files and directories, index files and the
+// other companions fortune(6) ignores are left alone, while binary files are
+// reported as skipped.
+//
+// Files that fail to index are listed in the summary rather than stopping
+// the walk; the returned error only reports a root that cannot be walked.
+func StrFileTree(root string, options BatchOptions) (BatchSummary, error) {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var (
+		summary BatchSummary
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+	)
+	sourceFiles := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sourceFile := range sourceFiles {
+				result, indexed, err := indexTreeFile(sourceFile, options)
+				mutex.Lock()
+				summary.add(sourceFile, result, indexed, err)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			mutex.Lock()
+			summary.Failed = append(summary.Failed, BatchFailure{SourceFile: path, Err: err})
+			mutex.Unlock()
+			return nil
+		}
+		if entry.IsDir() {
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if pkg.IsIgnoredFileName(entry.Name()) {
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			mutex.Lock()
+			summary.Skipped = append(summary.Skipped, path)
+			mutex.Unlock()
+			return nil
+		}
+		sourceFiles <- path
+		return nil
+	})
+	close(sourceFiles)
+	wg.Wait()
+
+	summary.sort()
+	return summary, err
+}
+
+// batchResult tells StrFileTree what became of a file.
+type batchResult int
+
+const (
+	batchIndexed batchResult = iota
+	batchUpToDate
+	batchSkipped
+)
+
+// indexTreeFile indexes sourceFile unless it is binary or its index is up to
+// date, and has the metadata index options ask for, returning the Summary of
+// the index it built.
+func indexTreeFile(sourceFile string, options BatchOptions) (batchResult, Summary, error) {
+	dataFile := sourceFile + ".dat"
+	missingMetadata := options.Metadata && !pkg.FileExists(pkg.MetadataIndexPath(dataFile))
+	if pkg.FileExists(dataFile) && !missingMetadata {
+		// Indexes that cannot be checked are rebuilt like stale ones.
+		if stale, err := pkg.IsStaleIndexFromPath(dataFile, sourceFile); err == nil && !stale {
+			return batchUpToDate, Summary{}, nil
+		}
+	}
+
+	binary, err := isBinaryPath(sourceFile)
+	if err != nil {
+		return batchSkipped, Summary{}, err
+	}
+	if binary {
+		return batchSkipped, Summary{}, nil
+	}
+
+	summary, err := StrFile(sourceFile, dataFile, options.Options)
+	return batchIndexed, summary, err
+}
+
+func isBinaryPath(inputFilePath string) (bool, error) {
+	inputFile, err := pkg.OpenDataFile(inputFilePath)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = inputFile.Close() }()
+	return pkg.IsBinaryFile(inputFile)
+}
+
+// add records what became of sourceFile.
+func (s *BatchSummary) add(sourceFile string, result batchResult, indexed Summary, err error) {
+	switch {
+	case err != nil:
+		s.Failed = append(s.Failed, BatchFailure{SourceFile: sourceFile, Err: err})
+	case result == batchIndexed:
+		s.Indexed = append(s.Indexed, indexed)
+	case result == batchUpToDate:
+		s.UpToDate = append(s.UpToDate, sourceFile)
+	default:
+		s.Skipped = append(s.Skipped, sourceFile)
+	}
+}
+
+func (s *BatchSummary) sort() {
+	sort.Slice(s.Indexed, func(i, j int) bool { return s.Indexed[i].DataFile < s.Indexed[j].DataFile })
+	sort.Strings(s.UpToDate)
+	sort.Strings(s.Skipped)
+	sort.Slice(s.Failed, func(i, j int) bool { return s.Failed[i].SourceFile < s.Failed[j].SourceFile })
+}
@@ -0,0 +1,114 @@
+package strfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files, keyed by slash-separated path relative to the
+// returned directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestStrFileTree(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"fresh":           "one\n%\ntwo\n%\n",
+		"sub/nested":      "three\n%\n",
+		"current":         "four\n%\n",
+		"stale":           "five\n%\n",
+		"image":           "\x89PNG\r\n\x1a\n\x00\x00",
+		".hidden/ignored": "six\n%\n",
+		// An index path that cannot be written fails only its own file.
+		"broken":            "seven\n%\n",
+		"broken.dat/.keep":  "",
+		"sub/nested.u8":     "three\n%\n",
+		"sub/fortunes.bak~": "\x00",
+	})
+	for _, name := range []string{"current", "stale"} {
+		path := filepath.Join(dir, name)
+		if _, err := StrFile(path, path+".dat", Options{}); err != nil {
+			t.Fatalf("strfile: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stale"), []byte("five, edited\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := StrFileTree(dir, BatchOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("strfile tree: %v", err)
+	}
+
+	relative := func(paths []string) []string {
+		names := make([]string, len(paths))
+		for i := range paths {
+			name, _ := filepath.Rel(dir, paths[i])
+			names[i] = filepath.ToSlash(name)
+		}
+		return names
+	}
+	var indexed []string
+	for _, s := range summary.Indexed {
+		indexed = append(indexed, s.DataFile)
+	}
+	if got, expected := relative(indexed), []string{"fresh.dat", "stale.dat", "sub/nested.dat"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("indexed: expected %v, got %v", expected, got)
+	}
+	if got, expected := relative(summary.UpToDate), []string{"current"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("up to date: expected %v, got %v", expected, got)
+	}
+	if got, expected := relative(summary.Skipped), []string{"image", "sub/fortunes.bak~"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("skipped: expected %v, got %v", expected, got)
+	}
+	if len(summary.Failed) != 1 || filepath.Base(summary.Failed[0].SourceFile) != "broken" {
+		t.Errorf("expected broken to fail, got %+v", summary.Failed)
+	}
+	if summary.Err() == nil {
+		t.Error("expected an error for the failed file")
+	}
+
+	total := summary.Total()
+	if total.TotalFortunes != 4 || total.LongestFortune != 13 || total.ShortestFortune != 4 {
+		t.Errorf("unexpected total %+v", total)
+	}
+	var report strings.Builder
+	if _, err := summary.WriteTo(&report); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	if !strings.HasPrefix(report.String(), "3 created, 1 up to date, 2 skipped, 1 failed\nThere were 4 strings\n") {
+		t.Errorf("unexpected report %q", report.String())
+	}
+
+	// A second run finds every index up to date.
+	if err := os.RemoveAll(filepath.Join(dir, "broken.dat")); err != nil {
+		t.Fatal(err)
+	}
+	summary, err = StrFileTree(dir, BatchOptions{})
+	if err != nil || summary.Err() != nil {
+		t.Fatalf("strfile tree: %v %v", err, summary.Err())
+	}
+	if len(summary.Indexed) != 1 || len(summary.UpToDate) != 4 {
+		t.Errorf("expected only broken to be indexed, got %+v", summary)
+	}
+}
+
+func TestStrFileTreeMissingRoot(t *testing.T) {
+	if _, err := StrFileTree(filepath.Join(t.TempDir(), "missing"), BatchOptions{}); err == nil {
+		t.Fatal("expected error for missing root, got nil")
+	}
+}
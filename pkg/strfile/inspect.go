@@ -0,0 +1,205 @@
+package strfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// IndexEntry is a decoded entry of an index offset table.
+type IndexEntry struct {
+	Position uint32  `json:"position"`
+	Offset   uint64  `json:"offset"`
+	Length   *uint32 `json:"length,omitempty"`  // Recorded in the extensions, if any
+	Preview  *string `json:"preview,omitempty"` // Start of the entry, as stored
+}
+
+// IndexInfo is the decoded contents of an index file.
+type IndexInfo struct {
+	DataFile        string       `json:"dataFile"`
+	Version         uint32       `json:"version"`
+	Layout          string       `json:"layout"`
+	NumberOfStrings uint32       `json:"numberOfStrings"`
+	LongestLength   uint32       `json:"longestLength"`
+	ShortestLength  uint32       `json:"shortestLength"`
+	Flags           uint32       `json:"flags"`
+	FlagNames       []string     `json:"flagNames"`
+	Delimiter       string       `json:"delimiter"`
+	Extensions      []string     `json:"extensions"`
+	Seed            *int64       `json:"seed,omitempty"`
+	Entries         []IndexEntry `json:"entries,omitempty"`
+}
+
+// InspectOptions tells Inspect how much of the offset table to decode.
+type InspectOptions struct {
+	// Offsets lists every entry of the offset table, end offset included.
+	Offsets bool
+	// SourceFile, when set along with Offsets, is read to preview up to
+	// PreviewLength bytes of each entry.
+	SourceFile    string
+	PreviewLength int
+}
+
+// Inspect decodes the header of the index dataFile and, following options,
+// its offset table.
+func Inspect(dataFile string, options InspectOptions) (IndexInfo, error) {
+	indexFile, err := os.Open(dataFile)
+	if err != nil {
+		return IndexInfo{}, err
+	}
+	defer func() { _ = indexFile.Close() }()
+
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return IndexInfo{}, err
+	}
+	format, table, err := pkg.DetectIndexFormat(indexFile, stat.Size())
+	if err != nil {
+		return IndexInfo{}, fmt.Errorf("load data table from %q: %w", dataFile, err)
+	}
+	extensions, err := pkg.LoadIndexExtensions(indexFile, stat.Size(), format, table)
+	if err != nil {
+		return IndexInfo{}, fmt.Errorf("load index extensions from %q: %w", dataFile, err)
+	}
+
+	info := IndexInfo{
+		DataFile:        dataFile,
+		Version:         table.Version,
+		Layout:          format.String(),
+		NumberOfStrings: table.NumberOfStrings,
+		LongestLength:   table.LongestLength,
+		ShortestLength:  table.ShortestLength,
+		Flags:           table.Flags,
+		FlagNames:       table.FlagNames(),
+		Delimiter:       pkg.IndexDelimiter(table, extensions),
+		Extensions:      []string{},
+	}
+	if extensions.HasLengths(table.NumberOfStrings) {
+		info.Extensions = append(info.Extensions, "lengths")
+	}
+	if extensions.Fingerprint != nil {
+		info.Extensions = append(info.Extensions, "fingerprint")
+	}
+	if extensions.Ordering != nil {
+		info.Extensions = append(info.Extensions, "ordering")
+	}
+	if extensions.Seed != nil {
+		info.Extensions = append(info.Extensions, "seed")
+		info.Seed = extensions.Seed
+	}
+	if extensions.Delimiter != "" {
+		info.Extensions = append(info.Extensions, "delimiter")
+	}
+	if !options.Offsets {
+		return info, nil
+	}
+
+	var sourceFile pkg.DataFile
+	if options.SourceFile != "" {
+		sourceFile, err = pkg.OpenDataFile(options.SourceFile)
+		if err != nil {
+			return info, err
+		}
+		defer func() { _ = sourceFile.Close() }()
+	}
+
+	for position := uint32(0); position <= table.NumberOfStrings; position++ {
+		dataPos, err := format.ReadDataPos(indexFile, position)
+		if err != nil {
+			if position == table.NumberOfStrings {
+				// Some writers omit the end offset.
+				break
+			}
+			return info, fmt.Errorf("read index file %q entry %d: %w", dataFile, position, err)
+		}
+		entry := IndexEntry{Position: position, Offset: dataPos.OriginalOffset}
+		if position < table.NumberOfStrings {
+			if extensions.HasLengths(table.NumberOfStrings) {
+				entry.Length = &extensions.Lengths[position]
+			}
+			if sourceFile != nil {
+				preview, err := previewEntry(sourceFile, dataPos.OriginalOffset, format.ReadDataEnd(indexFile, table, position), info.Delimiter, options.PreviewLength)
+				if err != nil {
+					return info, fmt.Errorf("read fortune file %q entry %d: %w", options.SourceFile, position, err)
+				}
+				entry.Preview = &preview
+			}
+		}
+		info.Entries = append(info.Entries, entry)
+	}
+	return info, nil
+}
+
+// previewEntry reads up to length bytes of the entry at offset.
+func previewEntry(sourceFile io.ReaderAt, offset uint64, end int64, delimiter string, length int) (string, error) {
+	data, err := pkg.ReadData(sourceFile, int64(offset), end, delimiter)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > length {
+		data = data[:length]
+	}
+	return data, nil
+}
+
+// WriteTo writes a human-readable description of info to w: the header
+// fields and, when decoded, the offset table with one entry per line.
+func (info IndexInfo) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	write := func(format string, args ...any) error {
+		n, err := fmt.Fprintf(w, format, args...)
+		total += int64(n)
+		return err
+	}
+
+	flags := "none"
+	if len(info.FlagNames) > 0 {
+		flags = fmt.Sprint(info.FlagNames)
+	}
+	extensions := "none"
+	if len(info.Extensions) > 0 {
+		extensions = fmt.Sprint(info.Extensions)
+	}
+	if err := write("File:       %s\nVersion:    %d (%s)\nStrings:    %d\nLongest:    %d bytes\nShortest:   %d bytes\nFlags:      %s (%#x)\nDelimiter:  %q\nExtensions: %s\n",
+		info.DataFile, info.Version, info.Layout, info.NumberOfStrings, info.LongestLength, info.ShortestLength,
+		flags, info.Flags, info.Delimiter, extensions); err != nil {
+		return total, err
+	}
+	if info.Seed != nil {
+		if err := write("Seed:       %d\n", *info.Seed); err != nil {
+			return total, err
+		}
+	}
+	if len(info.Entries) == 0 {
+		return total, nil
+	}
+
+	if err := write("\n"); err != nil {
+		return total, err
+	}
+	var buffer bytes.Buffer
+	table := tabwriter.NewWriter(&buffer, 0, 4, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprint(table, "POSITION\tOFFSET\tLENGTH\t\tPREVIEW\n")
+	for _, entry := range info.Entries {
+		length, preview := "-", ""
+		if entry.Length != nil {
+			length = fmt.Sprint(*entry.Length)
+		}
+		if entry.Preview != nil {
+			preview = fmt.Sprintf("%q", *entry.Preview)
+		}
+		if entry.Position == info.NumberOfStrings {
+			length, preview = "", "(end)"
+		}
+		_, _ = fmt.Fprintf(table, "%d\t%d\t%s\t\t%s\n", entry.Position, entry.Offset, length, preview)
+	}
+	if err := table.Flush(); err != nil {
+		return total, err
+	}
+	n, err := w.Write(buffer.Bytes())
+	return total + int64(n), err
+}
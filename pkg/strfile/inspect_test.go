@@ -0,0 +1,56 @@
+package strfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+func TestInspect(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if _, err := StrFile(sourceFile, dataFile, Options{Order: true, Rot13: true}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+
+	info, err := Inspect(dataFile, InspectOptions{Offsets: true, SourceFile: sourceFile, PreviewLength: 5})
+	if err != nil {
+		t.Fatalf("inspect: %v", err)
+	}
+	if info.Version != pkg.DefaultVersion || info.NumberOfStrings != 3 || info.Delimiter != "%" {
+		t.Errorf("unexpected header %+v", info)
+	}
+	if expected := []string{"FlagOrdered", "FlagRotated"}; !reflect.DeepEqual(info.FlagNames, expected) {
+		t.Errorf("expected flags %v, got %v", expected, info.FlagNames)
+	}
+	if expected := []string{"lengths", "fingerprint", "ordering"}; !reflect.DeepEqual(info.Extensions, expected) {
+		t.Errorf("expected extensions %v, got %v", expected, info.Extensions)
+	}
+
+	// Entries are listed in table order, end offset included, with previews
+	// as stored.
+	if len(info.Entries) != 4 {
+		t.Fatalf("expected 4 entries, got %+v", info.Entries)
+	}
+	expectedOffsets := []uint64{10, 30, 0, 38}
+	expectedPreviews := []string{"Alpha", "Bravo", "Charl"}
+	for i, entry := range info.Entries {
+		if entry.Offset != expectedOffsets[i] {
+			t.Errorf("entry %d: expected offset %d, got %d", i, expectedOffsets[i], entry.Offset)
+		}
+		if i < len(expectedPreviews) && (entry.Preview == nil || *entry.Preview != expectedPreviews[i]) {
+			t.Errorf("entry %d: expected preview %q, got %v", i, expectedPreviews[i], entry.Preview)
+		}
+	}
+
+	var out strings.Builder
+	if _, err := info.WriteTo(&out); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for _, expected := range []string{"Flags:      [FlagOrdered FlagRotated] (0x6)", `"Alpha"`, "(end)"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
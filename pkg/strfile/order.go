@@ -0,0 +1,35 @@
+package strfile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vromero/gofortune/pkg"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// newComparator returns the function ordering entry texts following
+// ordering: by pkg.OrderingKey, in code point order or with the collation of
+// ordering.Locale. Texts are expected to be case-folded already when
+// ordering.IgnoreCase is set.
+func newComparator(ordering pkg.Ordering) (func(a string, b string) int, error) {
+	if ordering.Locale == "" {
+		return func(a string, b string) int {
+			return strings.Compare(pkg.OrderingKey(a), pkg.OrderingKey(b))
+		}, nil
+	}
+
+	tag, err := language.Parse(ordering.Locale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locale %q: %w", ordering.Locale, err)
+	}
+	var options []collate.Option
+	if ordering.IgnoreCase {
+		options = append(options, collate.IgnoreCase)
+	}
+	collator := collate.New(tag, options...)
+	return func(a string, b string) int {
+		return collator.CompareString(pkg.OrderingKey(a), pkg.OrderingKey(b))
+	}, nil
+}
@@ -1,14 +1,49 @@
 package strfile
 
 import (
-	"math/rand"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
 
 	"github.com/vromero/gofortune/pkg"
 )
 
+// Shuffle puts input in a random order drawn from the global source.
 func Shuffle(input []pkg.DataPos) {
 	for i := range input {
-		j := rand.Intn(i + 1)
+		j := rand.IntN(i + 1)
 		input[i], input[j] = input[j], input[i]
 	}
 }
+
+// ShuffleWith puts input in a random order drawn from random, so a seeded
+// random always yields the same order.
+func ShuffleWith(input []pkg.DataPos, random *rand.Rand) {
+	for i := range input {
+		j := random.IntN(i + 1)
+		input[i], input[j] = input[j], input[i]
+	}
+}
+
+// NewSeededRand returns the generator seeded indexes are shuffled with, the
+// same as fortune.NewSeededRand gives for seed, so one seeded generator can
+// drive both.
+func NewSeededRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(seed), uint64(seed)))
+}
+
+// SourceDateEpoch returns the value of the SOURCE_DATE_EPOCH environment
+// variable that reproducible builds set, for use as a shuffle seed, and
+// whether it is set. See https://reproducible-builds.org/specs/source-date-epoch/.
+func SourceDateEpoch() (int64, bool, error) {
+	value, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok || value == "" {
+		return 0, false, nil
+	}
+	epoch, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", value, err)
+	}
+	return epoch, true, nil
+}
@@ -5,12 +5,15 @@ package strfile
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"math"
+	"math/rand/v2"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/vromero/gofortune/pkg"
 )
@@ -37,112 +40,277 @@ func (s Summary) WriteTo(w io.Writer) (int64, error) {
 	if err := write("%q created\n", s.DataFile); err != nil {
 		return total, err
 	}
+	err := s.writeStrings(write)
+	return total, err
+}
+
+// writeStrings reports the number and lengths of the strings in s through
+// write.
+func (s Summary) writeStrings(write func(format string, args ...any) error) error {
 	switch s.TotalFortunes {
 	case 0:
-		if err := write("There was no string\n"); err != nil {
-			return total, err
-		}
-		return total, nil
+		return write("There was no string\n")
 	case 1:
 		if err := write("There was 1 string\n"); err != nil {
-			return total, err
+			return err
 		}
 	default:
 		if err := write("There were %d strings\n", s.TotalFortunes); err != nil {
-			return total, err
+			return err
 		}
 	}
 	if err := write("Longest string: %d bytes\n", s.LongestFortune); err != nil {
-		return total, err
+		return err
 	}
-	if err := write("Shortest string: %d bytes\n", s.ShortestFortune); err != nil {
-		return total, err
+	return write("Shortest string: %d bytes\n", s.ShortestFortune)
+}
+
+// Options configures how an index is built. The zero value builds a classic
+// index listing entries delimited by "%" in file order.
+type Options struct {
+	// DelimitingChar is the line separating entries; "%" when empty. Any
+	// string pkg.ValidateDelimiter accepts can be used, such as "§" or
+	// "-- 8< --".
+	DelimitingChar string
+	// IgnoreCase folds case when ordering the entries.
+	IgnoreCase bool
+	// Order lists the entries in alphabetical order, comparing whole entries
+	// and ignoring their leading punctuation.
+	Order bool
+	// Locale, a BCP 47 tag such as "de" or "sv", orders the entries with the
+	// Unicode collation of that language instead of by code point.
+	Locale string
+	// Randomize lists the entries in random order.
+	Randomize bool
+	// Seeded builds reproducible indexes, byte-identical for identical input
+	// and options: they record no modification time, and Randomize draws
+	// their order from Seed, which they record.
+	Seeded bool
+	Seed   int64
+	// Rand, if set, is what an unseeded Randomize draws its order from
+	// instead of the global source.
+	Rand *rand.Rand
+	// Rot13 flags the entries as rot13'd; they are ordered by their decoded
+	// text.
+	Rot13 bool
+	// LargeFile writes a pkg.LargeFileVersion index with 64-bit offsets.
+	// Without it, input too large for the 32-bit offsets of the classic
+	// format fails with pkg.ErrOffsetOverflow.
+	LargeFile bool
+	// ModTime is recorded in the index as the modification time of the
+	// input, if set.
+	ModTime time.Time
+	// Metadata makes StrFile also write the metadata index of the entries,
+	// listing the attribution trailer of each one, at the path
+	// pkg.MetadataIndexPath gives for the index.
+	Metadata bool
+}
+
+// seeded reports whether the options build a seeded random index.
+func (options Options) seeded() bool {
+	return options.Randomize && options.Seeded
+}
+
+func (options Options) delimitingChar() string {
+	if options.DelimitingChar == "" {
+		return "%"
 	}
-	return total, nil
+	return options.DelimitingChar
 }
 
 // StrFile builds a fortune index at dataFile from sourceFile, returning a
 // Summary describing the index. The silent parameter has been removed from
 // this function signature; see cmd/strfile.go for user-facing silence
-// handling.
-func StrFile(ignoreCase bool, order bool, randomize bool, rot13 bool, delimitingChar string, sourceFile string, dataFile string) (summary Summary, err error) {
+// handling. The modification time of sourceFile is recorded in the index
+// whatever options.ModTime holds, unless options.Seeded asks for a
+// reproducible index. Compressed sources, see pkg.IsCompressedFileName, are
+// indexed by the offsets of their decompressed contents.
+//
+// The index is written with pkg.WriteFileAtomically: readers never observe a
+// partly written dataFile, and concurrent runs for the same dataFile take
+// turns.
+func StrFile(sourceFile string, dataFile string, options Options) (summary Summary, err error) {
 	summary.DataFile = dataFile
-	inputFile, err := os.Open(sourceFile)
+	stat, err := os.Stat(sourceFile)
 	if err != nil {
 		return summary, err
 	}
-	defer func() { _ = inputFile.Close() }()
-
-	outputFile, err := os.Create(dataFile)
+	inputFile, err := pkg.OpenDataReader(sourceFile)
 	if err != nil {
 		return summary, err
 	}
-	// Capture Close errors so a failed flush on the output index file does
-	// not silently produce a truncated/corrupt .dat file.
-	defer func() {
-		if cerr := outputFile.Close(); cerr != nil && err == nil {
-			err = fmt.Errorf("close %q: %w", dataFile, cerr)
+	defer func() { _ = inputFile.Close() }()
+	options.ModTime = time.Time{}
+	if !options.Seeded {
+		options.ModTime = stat.ModTime()
+	}
+
+	err = pkg.WriteFileAtomically(dataFile, func(outputFile *os.File) error {
+		if !options.Metadata {
+			summary, err = Build(inputFile, outputFile, options)
+			return err
 		}
-	}()
+		// The metadata index lands first; a failed index leaves it
+		// describing a fingerprint no index has, which readers ignore.
+		return pkg.WriteFileAtomically(pkg.MetadataIndexPath(dataFile), func(metadataFile *os.File) error {
+			summary, err = BuildWithMetadata(inputFile, outputFile, metadataFile, options)
+			return err
+		})
+	})
+	summary.DataFile = dataFile
+	return summary, err
+}
 
-	scanner := bufio.NewScanner(inputFile)
-	scanner.Split(advanceAwareSplitter)
+// Index builds in memory the index StrFile would write for the fortunes read
+// from inputFile and returns it encoded. As the index never reaches the disk
+// it always uses 64-bit offsets, so input of any size can be indexed.
+func Index(inputFile io.Reader, options Options) ([]byte, error) {
+	var outputFile pkg.WriteAtBuffer
+	options.LargeFile = true
+	if _, err := Build(inputFile, &outputFile, options); err != nil {
+		return nil, err
+	}
+	return outputFile.Bytes(), nil
+}
+
+// Build scans the fortunes read from inputFile and writes their index to
+// outputFile, which may be a file, a pkg.WriteAtBuffer or any other
+// io.WriterAt. The input is read once, from start to end, so it can be a
+// stream. The index records a fingerprint of the input. The DataFile of the
+// returned Summary is left empty.
+func Build(inputFile io.Reader, outputFile io.WriterAt, options Options) (Summary, error) {
+	return BuildWithMetadata(inputFile, outputFile, nil, options)
+}
+
+// BuildWithMetadata is Build also writing to metadataFile, unless nil, the
+// pkg.MetadataIndex of the entries, parsing their attribution trailer as
+// they are scanned.
+func BuildWithMetadata(inputFile io.Reader, outputFile io.WriterAt, metadataFile io.Writer, options Options) (summary Summary, err error) {
+	ignoreCase, order, randomize, rot13, largeFile := options.IgnoreCase, options.Order, options.Randomize, options.Rot13, options.LargeFile
+	delimitingChar := options.delimitingChar()
+	if err := pkg.ValidateDelimiter(delimitingChar); err != nil {
+		return summary, err
+	}
+	ordering := pkg.Ordering{IgnoreCase: ignoreCase, Locale: options.Locale}
+	compare, err := newComparator(ordering)
+	if err != nil {
+		return summary, err
+	}
+	hash := sha256.New()
 
 	var totalFortunes, longestFortune uint32
 	var shortestFortune uint32 = math.MaxUint32
-	var pos uint32
-	var fortuneBytes []byte
 
-	fortuneBase := make([]pkg.DataPos, 0)
+	format := pkg.DefaultIndexFormat
+	if largeFile {
+		format = pkg.LargeFileIndexFormat
+	}
 
-	for scanner.Scan() {
-		fortunePortion := scanner.Bytes()
-		pos += uint32(len(fortunePortion))
+	fortuneBase := make([]pkg.DataPos, 0)
+	// Entry lengths as readers return them, without the final line break, in
+	// table order; recorded in the index extensions for exact length filters.
+	lengths := make([]uint32, 0)
+	// Attributions of the entries in file order and, for reordered tables,
+	// by start offset.
+	var attributions []pkg.Attribution
+	attributionsByStart := make(map[uint64]pkg.Attribution)
 
-		if string(pkg.RemoveCRLF(fortunePortion)) == delimitingChar {
-			totalFortunes++
-			fortuneStringLength := uint32(len(fortuneBytes))
-			shortestFortune = pkg.Min(shortestFortune, fortuneStringLength)
-			longestFortune = pkg.Max(longestFortune, fortuneStringLength)
-
-			transformedString := applyFortuneTransformations(string(fortuneBytes), ignoreCase, rot13)
-			dataPos := pkg.DataPos{OriginalOffset: pos, Text: transformedString}
-			if !order && !randomize {
-				if werr := pkg.WriteDataPos(outputFile, int(pkg.DataTableSize), totalFortunes, dataPos); werr != nil {
-					return summary, werr
-				}
+	pos, err := scanFortunes(io.TeeReader(inputFile, hash), delimitingChar, func(start uint64, end uint64, fortuneBytes []byte) error {
+		totalFortunes++
+		fortuneStringLength := uint32(len(fortuneBytes))
+		shortestFortune = pkg.Min(shortestFortune, fortuneStringLength)
+		longestFortune = pkg.Max(longestFortune, fortuneStringLength)
+		entryLength := uint32(len(pkg.RemoveCRLF(fortuneBytes)))
+		if metadataFile != nil {
+			attribution := parseAttribution(fortuneBytes, rot13)
+			if order || randomize {
+				attributionsByStart[start] = attribution
 			} else {
-				fortuneBase = append(fortuneBase, dataPos)
+				attributions = append(attributions, attribution)
 			}
-
-			fortuneBytes = make([]byte, 0)
-		} else {
-			fortuneBytes = append(fortuneBytes[:], fortunePortion[:]...)
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
+		if !order && !randomize {
+			// Unordered tables are written as they are scanned: position 0
+			// is the start of the file (left zeroed) and position i holds
+			// the offset just past the i-th delimiter.
+			lengths = append(lengths, entryLength)
+			return format.WriteDataPos(outputFile, totalFortunes, pkg.DataPos{OriginalOffset: end})
+		}
+		// Reordered tables need each entry paired with its own start
+		// offset so sorting or shuffling keeps text and offset together.
+		transformedString := applyFortuneTransformations(string(fortuneBytes), ignoreCase, rot13)
+		fortuneBase = append(fortuneBase, pkg.DataPos{OriginalOffset: start, Text: transformedString, Length: entryLength})
+		return nil
+	})
+	if err != nil {
 		return summary, err
 	}
 
 	flags := calculateFlags(randomize, order, rot13)
 	posContents := pkg.CreateDataTable(totalFortunes, longestFortune, shortestFortune, flags, delimitingChar)
+	if largeFile {
+		posContents.Version = pkg.LargeFileVersion
+	}
 	if err := pkg.SaveDataTable(outputFile, posContents); err != nil {
 		return summary, err
 	}
 
 	if order {
-		sort.Slice(fortuneBase, func(i, j int) bool {
-			return pkg.LessThanDataPos(fortuneBase[i], fortuneBase[j])
+		// Entries that compare equal keep their file order.
+		sort.SliceStable(fortuneBase, func(i, j int) bool {
+			return compare(fortuneBase[i].Text, fortuneBase[j].Text) < 0
 		})
+	} else if options.seeded() {
+		ShuffleWith(fortuneBase, NewSeededRand(options.Seed))
+	} else if randomize && options.Rand != nil {
+		ShuffleWith(fortuneBase, options.Rand)
 	} else if randomize {
 		Shuffle(fortuneBase)
 	}
 
 	if order || randomize {
-		if werr := pkg.WriteDataPosSlice(outputFile, int(pkg.DataTableSize), fortuneBase); werr != nil {
+		if werr := format.WriteDataPosSlice(outputFile, fortuneBase); werr != nil {
+			return summary, werr
+		}
+		// Like the classic strfile, the table ends with the number of bytes
+		// read, the end of the file, regardless of how the entries were
+		// reordered. It lies past the last delimiter only when no text
+		// follows it.
+		if werr := format.WriteDataPos(outputFile, totalFortunes, pkg.DataPos{OriginalOffset: pos}); werr != nil {
 			return summary, werr
 		}
+		for i := range fortuneBase {
+			lengths = append(lengths, fortuneBase[i].Length)
+			if metadataFile != nil {
+				attributions = append(attributions, attributionsByStart[fortuneBase[i].OriginalOffset])
+			}
+		}
+	}
+
+	fingerprint := &pkg.SourceFingerprint{Size: pos}
+	if !options.ModTime.IsZero() {
+		fingerprint.ModTime = options.ModTime.UnixNano()
+	}
+	copy(fingerprint.SHA256[:], hash.Sum(nil))
+	extensions := pkg.IndexExtensions{Lengths: lengths, Fingerprint: fingerprint}
+	if order {
+		extensions.Ordering = &ordering
+	}
+	if options.seeded() {
+		extensions.Seed = &options.Seed
+	}
+	if len(delimitingChar) > 1 {
+		extensions.Delimiter = delimitingChar
+	}
+	if err := pkg.SaveIndexExtensions(outputFile, format, posContents, extensions); err != nil {
+		return summary, err
+	}
+	if metadataFile != nil {
+		metadata := pkg.MetadataIndex{SourceSHA256: fingerprint.SHA256, Attributions: attributions}
+		if err := pkg.SaveMetadataIndex(metadataFile, metadata); err != nil {
+			return summary, err
+		}
 	}
 
 	summary.TotalFortunes = totalFortunes
@@ -151,6 +319,34 @@ func StrFile(ignoreCase bool, order bool, randomize bool, rot13 bool, delimiting
 	return summary, nil
 }
 
+// scanFortunes reads the entries of inputFile, each terminated by a line
+// holding delimitingChar, and calls visit for each one in file order with the
+// offsets of its first byte and of the byte past its delimiter line and its
+// text, delimiter excluded. Text after the last delimiter is not an entry.
+// It returns the number of bytes read.
+func scanFortunes(inputFile io.Reader, delimitingChar string, visit func(start uint64, end uint64, fortuneBytes []byte) error) (uint64, error) {
+	scanner := bufio.NewScanner(inputFile)
+	scanner.Split(advanceAwareSplitter)
+
+	var pos, start uint64
+	var fortuneBytes []byte
+	for scanner.Scan() {
+		fortunePortion := scanner.Bytes()
+		pos += uint64(len(fortunePortion))
+
+		if string(pkg.RemoveCRLF(fortunePortion)) == delimitingChar {
+			if err := visit(start, pos, fortuneBytes); err != nil {
+				return pos, err
+			}
+			start = pos
+			fortuneBytes = make([]byte, 0)
+		} else {
+			fortuneBytes = append(fortuneBytes, fortunePortion...)
+		}
+	}
+	return pos, scanner.Err()
+}
+
 func calculateFlags(randomize bool, order bool, rot13 bool) (flags uint32) {
 	if randomize {
 		flags = flags | pkg.FlagRandom
@@ -166,14 +362,26 @@ func calculateFlags(randomize bool, order bool, rot13 bool) (flags uint32) {
 	return
 }
 
+// parseAttribution parses the attribution trailer of an entry as readers
+// return it: decoded when rot13'd.
+func parseAttribution(fortuneBytes []byte, rot13 bool) pkg.Attribution {
+	text := string(pkg.RemoveCRLF(fortuneBytes))
+	if rot13 {
+		text = pkg.Rot13(text)
+	}
+	attribution, _ := pkg.ParseAttribution(text)
+	return attribution
+}
+
+// applyFortuneTransformations returns the text input is ordered by: decoded
+// when the collection is rot13'd, and case-folded with ignoreCase.
 func applyFortuneTransformations(input string, ignoreCase bool, rot13 bool) (output string) {
 	output = input
+	if rot13 {
+		output = pkg.Rot13(output)
+	}
 	if ignoreCase {
 		output = strings.ToLower(output)
 	}
-
-	if rot13 {
-		output = pkg.Rot13(input)
-	}
 	return
 }
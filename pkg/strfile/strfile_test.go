@@ -2,7 +2,14 @@ package strfile
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vromero/gofortune/pkg"
 )
@@ -69,7 +76,7 @@ func TestShufflePreservesElements(t *testing.T) {
 		t.Fatalf("length changed: before=%d after=%d", len(original), len(shuffled))
 	}
 
-	seen := make(map[uint32]int, len(original))
+	seen := make(map[uint64]int, len(original))
 	for _, d := range shuffled {
 		seen[d.OriginalOffset]++
 	}
@@ -85,3 +92,228 @@ func TestShuffleEmpty(t *testing.T) {
 	Shuffle(nil)
 	Shuffle([]pkg.DataPos{})
 }
+
+// TestStrFileRecordsEntryLengths verifies that entry lengths are recorded in
+// the index extensions in table order.
+func TestStrFileRecordsEntryLengths(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if _, err := StrFile(sourceFile, dataFile, Options{Order: true}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+
+	format, table, err := pkg.DetectIndexFormatFromPath(dataFile)
+	if err != nil {
+		t.Fatalf("detect format: %v", err)
+	}
+	extensions, err := pkg.LoadIndexExtensionsFromPath(dataFile, format, table)
+	if err != nil {
+		t.Fatalf("load extensions: %v", err)
+	}
+	// Ordered: "Alpha\nstill alpha", "Bravo", "Charlie".
+	expected := []uint32{17, 5, 7}
+	if !reflect.DeepEqual(extensions.Lengths, expected) {
+		t.Errorf("expected lengths %v, got %v", expected, extensions.Lengths)
+	}
+}
+
+// TestBuildFromStream verifies that indexing a stream into memory yields the
+// same index StrFile writes for the same contents.
+func TestBuildFromStream(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	options := Options{Order: true, IgnoreCase: true}
+	if _, err := StrFile(sourceFile, dataFile, options); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+	expected, err := os.ReadFile(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(sourceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var index pkg.WriteAtBuffer
+	options.ModTime = stat.ModTime()
+	summary, err := Build(strings.NewReader(unstrSource), &index, options)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if summary.TotalFortunes != 3 || summary.DataFile != "" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if !bytes.Equal(index.Bytes(), expected) {
+		t.Errorf("expected the index StrFile wrote, got a different one")
+	}
+}
+
+func TestStrFileOrdering(t *testing.T) {
+	const source = "\"zebra\"\n%\napple\n%\nÉclair\n%\nBanana\n%\n"
+	tests := map[string]struct {
+		options  Options
+		expected string
+	}{
+		"code point":  {Options{Order: true}, "Banana\n%\napple\n%\n\"zebra\"\n%\nÉclair\n%\n"},
+		"ignore case": {Options{Order: true, IgnoreCase: true}, "apple\n%\nBanana\n%\n\"zebra\"\n%\nÉclair\n%\n"},
+		"locale":      {Options{Order: true, Locale: "fr"}, "apple\n%\nBanana\n%\nÉclair\n%\n\"zebra\"\n%\n"},
+		// Stored rot13'd, the entries decode to "mroen", "nccyr", "Épynve"
+		// and "Onanan".
+		"rot13 ignore case": {Options{Order: true, IgnoreCase: true, Rot13: true}, "\"zebra\"\n%\napple\n%\nBanana\n%\nÉclair\n%\n"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			sourceFile, dataFile := dir+"/fortunes", dir+"/fortunes.dat"
+			if err := os.WriteFile(sourceFile, []byte(source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := StrFile(sourceFile, dataFile, test.options); err != nil {
+				t.Fatalf("strfile: %v", err)
+			}
+
+			var out bytes.Buffer
+			if err := Unstr(sourceFile, dataFile, &out); err != nil {
+				t.Fatalf("unstr: %v", err)
+			}
+			if out.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, out.String())
+			}
+			report, err := Verify(sourceFile, dataFile)
+			if err != nil || !report.OK() {
+				t.Errorf("expected the index to verify, got %+v (err=%v)", report.Discrepancies, err)
+			}
+		})
+	}
+}
+
+func TestStrFileInvalidLocale(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if _, err := StrFile(sourceFile, dataFile, Options{Order: true, Locale: "not a locale"}); err == nil {
+		t.Fatal("expected error for invalid locale, got nil")
+	}
+}
+
+func TestStrFileInvalidDelimiter(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if _, err := StrFile(sourceFile, dataFile, Options{DelimitingChar: "% "}); !errors.Is(err, pkg.ErrInvalidDelimiter) {
+		t.Fatalf("expected ErrInvalidDelimiter, got %v", err)
+	}
+}
+
+// TestStrFileSeededIsReproducible verifies that seeded random indexes of the
+// same contents are byte-identical whatever the file times, and record their
+// seed.
+func TestStrFileSeededIsReproducible(t *testing.T) {
+	var source strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&source, "fortune %d\n%%\n", i)
+	}
+
+	build := func(seed int64, modTime time.Time) []byte {
+		dir := t.TempDir()
+		sourceFile, dataFile := filepath.Join(dir, "fortunes"), filepath.Join(dir, "fortunes.dat")
+		if err := os.WriteFile(sourceFile, []byte(source.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(sourceFile, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := StrFile(sourceFile, dataFile, Options{Randomize: true, Seeded: true, Seed: seed}); err != nil {
+			t.Fatalf("strfile: %v", err)
+		}
+		data, err := os.ReadFile(dataFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	first := build(42, time.Unix(1000, 0))
+	if second := build(42, time.Unix(2000, 0)); !bytes.Equal(first, second) {
+		t.Error("expected identical indexes for the same seed")
+	}
+	if other := build(43, time.Unix(1000, 0)); bytes.Equal(first, other) {
+		t.Error("expected different indexes for different seeds")
+	}
+
+	format, table, err := pkg.DetectIndexFormat(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatalf("detect format: %v", err)
+	}
+	extensions, err := pkg.LoadIndexExtensions(bytes.NewReader(first), int64(len(first)), format, table)
+	if err != nil {
+		t.Fatalf("load extensions: %v", err)
+	}
+	if extensions.Seed == nil || *extensions.Seed != 42 || extensions.Fingerprint.ModTime != 0 {
+		t.Errorf("expected seed 42 and no modification time, got %+v", extensions)
+	}
+
+	// The order drawn from a seed is part of the format of reproducible
+	// packages: changing it changes every seeded index.
+	offset := func(i uint64) uint64 {
+		if i < 10 {
+			return 12 * i
+		}
+		return 120 + 13*(i-10)
+	}
+	for position, want := range []uint64{40, 6, 14, 1, 23, 45, 5, 49} {
+		got, err := format.ReadDataPos(bytes.NewReader(first), uint32(position))
+		if err != nil || got.OriginalOffset != offset(want) {
+			t.Errorf("position %d: expected fortune %d at offset %d, got %d (err=%v)", position, want, offset(want), got.OriginalOffset, err)
+		}
+	}
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if epoch, ok, err := SourceDateEpoch(); err != nil || !ok || epoch != 1700000000 {
+		t.Errorf("expected 1700000000, got %d %v (err=%v)", epoch, ok, err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, _, err := SourceDateEpoch(); err == nil {
+		t.Error("expected error for invalid SOURCE_DATE_EPOCH")
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if _, ok, err := SourceDateEpoch(); err != nil || ok {
+		t.Errorf("expected unset, got %v (err=%v)", ok, err)
+	}
+}
+
+// TestStrFileMetadata verifies that the metadata index lists the attribution
+// of each entry in the order the offset table lists them.
+func TestStrFileMetadata(t *testing.T) {
+	dir := t.TempDir()
+	sourceFile, dataFile := filepath.Join(dir, "quotes"), filepath.Join(dir, "quotes.dat")
+	source := "Zeal.\n\t-- Oscar Wilde\n%\nNo trailer\n%\nAll of it.\n\t-- Mark Twain, \"Following the Equator\" (1897)\n%\n"
+	if err := os.WriteFile(sourceFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StrFile(sourceFile, dataFile, Options{Order: true, Metadata: true}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+
+	metadata, err := pkg.LoadMetadataIndexFromPath(filepath.Join(dir, "quotes.meta"))
+	if err != nil {
+		t.Fatalf("load metadata: %v", err)
+	}
+	expected := []pkg.Attribution{
+		{Author: "Mark Twain", Source: "Following the Equator", Year: 1897},
+		{},
+		{Author: "Oscar Wilde"},
+	}
+	if !reflect.DeepEqual(metadata.Attributions, expected) {
+		t.Errorf("expected %+v, got %+v", expected, metadata.Attributions)
+	}
+
+	format, table, err := pkg.DetectIndexFormatFromPath(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extensions, err := pkg.LoadIndexExtensionsFromPath(dataFile, format, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !metadata.Matches(table, extensions) {
+		t.Error("expected the metadata to match the index")
+	}
+}
@@ -0,0 +1,60 @@
+package strfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// Unstr undoes the work of StrFile: it writes every entry of sourceFile to w
+// in the order listed by the index dataFile, each one followed by a line
+// holding the delimiter recorded in the index. Indexes built with
+// ordering or randomization therefore produce a sorted or shuffled copy of
+// the source.
+func Unstr(sourceFile string, dataFile string, w io.Writer) error {
+	indexFile, err := os.Open(dataFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = indexFile.Close() }()
+
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return err
+	}
+	format, table, err := pkg.DetectIndexFormat(indexFile, stat.Size())
+	if err != nil {
+		return fmt.Errorf("load data table from %q: %w", dataFile, err)
+	}
+	extensions, err := pkg.LoadIndexExtensions(indexFile, stat.Size(), format, table)
+	if err != nil {
+		return fmt.Errorf("load index extensions from %q: %w", dataFile, err)
+	}
+	delimiter := pkg.IndexDelimiter(table, extensions)
+
+	inputFile, err := pkg.OpenDataFile(sourceFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = inputFile.Close() }()
+
+	output := bufio.NewWriter(w)
+	for i := uint32(0); i < table.NumberOfStrings; i++ {
+		dataPos, err := format.ReadDataPos(indexFile, i)
+		if err != nil {
+			return fmt.Errorf("read index file %q entry %d: %w", dataFile, i, err)
+		}
+		end := format.ReadDataEnd(indexFile, table, i)
+		data, err := pkg.ReadData(inputFile, int64(dataPos.OriginalOffset), end, delimiter)
+		if err != nil {
+			return fmt.Errorf("read fortune file %q entry %d: %w", sourceFile, i, err)
+		}
+		if _, err := fmt.Fprintf(output, "%s\n%s\n", data, delimiter); err != nil {
+			return err
+		}
+	}
+	return output.Flush()
+}
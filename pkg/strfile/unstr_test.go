@@ -0,0 +1,127 @@
+package strfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+const unstrSource = "Charlie\n%\nAlpha\nstill alpha\n%\nBravo\n%\n"
+
+func writeUnstrSource(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	sourceFile := filepath.Join(dir, "fortunes")
+	if err := os.WriteFile(sourceFile, []byte(unstrSource), 0644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	return sourceFile, sourceFile + ".dat"
+}
+
+// TestUnstrRoundTrip verifies that an unordered index reproduces the source
+// file byte for byte.
+func TestUnstrRoundTrip(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if _, err := StrFile(sourceFile, dataFile, Options{}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Unstr(sourceFile, dataFile, &out); err != nil {
+		t.Fatalf("unstr: %v", err)
+	}
+	if out.String() != unstrSource {
+		t.Errorf("expected %q, got %q", unstrSource, out.String())
+	}
+}
+
+// TestUnstrFollowsOrderedIndex verifies that entries are written in index
+// order, so an ordered index yields a sorted copy of the source.
+func TestUnstrFollowsOrderedIndex(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if _, err := StrFile(sourceFile, dataFile, Options{Order: true}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Unstr(sourceFile, dataFile, &out); err != nil {
+		t.Fatalf("unstr: %v", err)
+	}
+	expected := "Alpha\nstill alpha\n%\nBravo\n%\nCharlie\n%\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+// TestUnstrLargeFileIndex verifies that indexes with 64-bit offsets are
+// written with the large file version and read back.
+func TestUnstrLargeFileIndex(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if _, err := StrFile(sourceFile, dataFile, Options{LargeFile: true}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+
+	format, table, err := pkg.DetectIndexFormatFromPath(dataFile)
+	if err != nil {
+		t.Fatalf("detect format: %v", err)
+	}
+	if table.Version != pkg.LargeFileVersion || format.OffsetSize != 8 {
+		t.Errorf("expected version %d with 64-bit offsets, got version %d with %v", pkg.LargeFileVersion, table.Version, format)
+	}
+
+	var out bytes.Buffer
+	if err := Unstr(sourceFile, dataFile, &out); err != nil {
+		t.Fatalf("unstr: %v", err)
+	}
+	if out.String() != unstrSource {
+		t.Errorf("expected %q, got %q", unstrSource, out.String())
+	}
+}
+
+// TestUnstrMissingIndex verifies that a missing index is reported as an error.
+func TestUnstrMissingIndex(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if err := Unstr(sourceFile, dataFile, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error for missing index, got nil")
+	}
+}
+
+// TestUnstrMultiByteDelimiter verifies that delimiters longer than the byte
+// the header holds are recorded in the index and written back whole.
+func TestUnstrMultiByteDelimiter(t *testing.T) {
+	for _, delimiter := range []string{"§", "※※", "-- 8< --"} {
+		t.Run(delimiter, func(t *testing.T) {
+			source := "first\n" + delimiter + "\nsecond\n" + delimiter + "x\n" + delimiter + "\nthird\n" + delimiter + "\n"
+			sourceFile := filepath.Join(t.TempDir(), "fortunes")
+			if err := os.WriteFile(sourceFile, []byte(source), 0644); err != nil {
+				t.Fatalf("write source: %v", err)
+			}
+			summary, err := StrFile(sourceFile, sourceFile+".dat", Options{DelimitingChar: delimiter})
+			if err != nil {
+				t.Fatalf("strfile: %v", err)
+			}
+			if summary.TotalFortunes != 3 {
+				t.Errorf("expected 3 strings, got %d", summary.TotalFortunes)
+			}
+
+			var out bytes.Buffer
+			if err := Unstr(sourceFile, sourceFile+".dat", &out); err != nil {
+				t.Fatalf("unstr: %v", err)
+			}
+			if out.String() != source {
+				t.Errorf("expected %q, got %q", source, out.String())
+			}
+
+			report, err := Verify(sourceFile, sourceFile+".dat")
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if !report.OK() {
+				t.Errorf("unexpected discrepancies: %+v", report.Discrepancies)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package strfile
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+// VerifyCheck names the property of an index a Discrepancy breaks.
+type VerifyCheck string
+
+const (
+	CheckCount       VerifyCheck = "count"       // NumberOfStrings differs from the entries in the file
+	CheckLongest     VerifyCheck = "longest"     // LongestLength differs from the longest entry
+	CheckShortest    VerifyCheck = "shortest"    // ShortestLength differs from the shortest entry
+	CheckOffset      VerifyCheck = "offset"      // An offset is not the start of an entry, or is listed twice
+	CheckEnd         VerifyCheck = "end"         // The end offset is not the end of the last entry
+	CheckOrder       VerifyCheck = "order"       // Entries are not listed in the order the flags promise
+	CheckFlags       VerifyCheck = "flags"       // Flags are unknown or contradict each other
+	CheckExtensions  VerifyCheck = "extensions"  // The extension block is unreadable
+	CheckLengths     VerifyCheck = "lengths"     // Recorded entry lengths differ from the entries
+	CheckFingerprint VerifyCheck = "fingerprint" // The file changed after the index was built
+)
+
+// knownFlags are the header flags strfile defines.
+const knownFlags = pkg.FlagRandom | pkg.FlagOrdered | pkg.FlagRotated
+
+// Discrepancy is a mismatch between an index and the file it indexes.
+// Position is the table position of the entry involved, if any.
+type Discrepancy struct {
+	Check    VerifyCheck `json:"check"`
+	Position *uint32     `json:"position,omitempty"`
+	Message  string      `json:"message"`
+}
+
+// VerifyReport lists every Discrepancy Verify found.
+type VerifyReport struct {
+	SourceFile    string        `json:"sourceFile"`
+	DataFile      string        `json:"dataFile"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// OK reports whether the index matches its file.
+func (r VerifyReport) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// WriteTo writes one line per discrepancy of r to w, in the form
+// "datafile: check[position]: message".
+func (r VerifyReport) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, discrepancy := range r.Discrepancies {
+		check := string(discrepancy.Check)
+		if discrepancy.Position != nil {
+			check = fmt.Sprintf("%s[%d]", check, *discrepancy.Position)
+		}
+		n, err := fmt.Fprintf(w, "%s: %s: %s\n", r.DataFile, check, discrepancy.Message)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (r *VerifyReport) add(check VerifyCheck, format string, args ...any) {
+	r.Discrepancies = append(r.Discrepancies, Discrepancy{Check: check, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *VerifyReport) addAt(check VerifyCheck, position uint32, format string, args ...any) {
+	r.Discrepancies = append(r.Discrepancies, Discrepancy{Check: check, Position: &position, Message: fmt.Sprintf(format, args...)})
+}
+
+// scannedEntry is an entry of a fortune file as Verify finds it.
+type scannedEntry struct {
+	start uint64
+	text  []byte
+}
+
+// Verify re-scans sourceFile and checks the index dataFile against it: every
+// offset must start an entry, each entry must be listed once, the header
+// counts and flags must match the entries and their order, and the entry
+// lengths and fingerprint recorded in the extensions, when present, must
+// still hold. Mismatches are listed in the report; the returned error only
+// reports files that cannot be read or an index that cannot be parsed at all.
+func Verify(sourceFile string, dataFile string) (VerifyReport, error) {
+	report := VerifyReport{SourceFile: sourceFile, DataFile: dataFile, Discrepancies: []Discrepancy{}}
+
+	indexFile, err := os.Open(dataFile)
+	if err != nil {
+		return report, err
+	}
+	defer func() { _ = indexFile.Close() }()
+
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return report, err
+	}
+	format, table, err := pkg.DetectIndexFormat(indexFile, stat.Size())
+	if err != nil {
+		return report, fmt.Errorf("load data table from %q: %w", dataFile, err)
+	}
+	// The extensions are needed first to know the delimiter entries are
+	// split on.
+	extensions, err := pkg.LoadIndexExtensions(indexFile, stat.Size(), format, table)
+	if err != nil {
+		report.add(CheckExtensions, "%v", err)
+		extensions = pkg.IndexExtensions{}
+	}
+
+	inputFile, err := pkg.OpenDataReader(sourceFile)
+	if err != nil {
+		return report, err
+	}
+	defer func() { _ = inputFile.Close() }()
+
+	var entries []scannedEntry
+	var lastEnd uint64
+	var longest uint32
+	var shortest uint32 = math.MaxUint32
+	size, err := scanFortunes(inputFile, pkg.IndexDelimiter(table, extensions), func(start uint64, end uint64, fortuneBytes []byte) error {
+		entries = append(entries, scannedEntry{start: start, text: fortuneBytes})
+		lastEnd = end
+		longest = pkg.Max(longest, uint32(len(fortuneBytes)))
+		shortest = pkg.Min(shortest, uint32(len(fortuneBytes)))
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("read %q: %w", sourceFile, err)
+	}
+
+	if table.NumberOfStrings != uint32(len(entries)) {
+		report.add(CheckCount, "index lists %d strings, file holds %d", table.NumberOfStrings, len(entries))
+	}
+	if table.LongestLength != longest {
+		report.add(CheckLongest, "index records %d bytes, longest string has %d", table.LongestLength, longest)
+	}
+	if table.ShortestLength != shortest {
+		report.add(CheckShortest, "index records %d bytes, shortest string has %d", table.ShortestLength, shortest)
+	}
+
+	if unknown := table.Flags &^ knownFlags; unknown != 0 {
+		report.add(CheckFlags, "unknown flags %#x", unknown)
+	}
+	if table.Flags&pkg.FlagRandom != 0 && table.Flags&pkg.FlagOrdered != 0 {
+		report.add(CheckFlags, "index is flagged both random and ordered")
+	}
+
+	listed := verifyOffsets(&report, indexFile, format, table, entries)
+	verifyEnd(&report, indexFile, format, table, lastEnd, size)
+
+	if table.Flags&pkg.FlagOrdered != 0 && table.Flags&pkg.FlagRandom == 0 {
+		if err := verifyOrdered(&report, table, extensions.Ordering, listed); err != nil {
+			report.add(CheckExtensions, "%v", err)
+		}
+	}
+	if extensions.Lengths != nil {
+		verifyLengths(&report, extensions.Lengths, listed)
+	}
+	if extensions.Fingerprint != nil {
+		matches, err := extensions.Fingerprint.MatchesPath(sourceFile)
+		if err != nil {
+			return report, err
+		}
+		if !matches {
+			report.add(CheckFingerprint, "%q changed after the index was built", sourceFile)
+		}
+	}
+	return report, nil
+}
+
+// verifyOffsets checks that every offset of the table starts one of entries,
+// that no entry is listed twice and, for tables neither ordered nor
+// randomized, that entries are listed in file order. It returns the entries
+// in table order, nil for positions that start no entry.
+func verifyOffsets(report *VerifyReport, index io.ReaderAt, format pkg.IndexFormat, table pkg.DataTable, entries []scannedEntry) []*scannedEntry {
+	byStart := make(map[uint64]*scannedEntry, len(entries))
+	for i := range entries {
+		byStart[entries[i].start] = &entries[i]
+	}
+	reordered := table.Flags&(pkg.FlagRandom|pkg.FlagOrdered) != 0
+
+	listed := make([]*scannedEntry, 0, len(entries))
+	seen := make(map[uint64]bool, len(entries))
+	for position := uint32(0); position < table.NumberOfStrings; position++ {
+		dataPos, err := format.ReadDataPos(index, position)
+		if err != nil {
+			report.addAt(CheckOffset, position, "cannot read offset: %v", err)
+			break
+		}
+		offset := dataPos.OriginalOffset
+		entry := byStart[offset]
+		listed = append(listed, entry)
+		switch {
+		case entry == nil:
+			report.addAt(CheckOffset, position, "offset %d is not the start of a string", offset)
+		case seen[offset]:
+			report.addAt(CheckOffset, position, "string at offset %d is listed more than once", offset)
+		case !reordered && (int(position) >= len(entries) || entries[position].start != offset):
+			report.addAt(CheckOrder, position, "string at offset %d is out of file order in an index flagged neither random nor ordered", offset)
+		}
+		seen[offset] = true
+	}
+	return listed
+}
+
+// verifyEnd checks the offset the table ends with, which strfile sets to the
+// end of the file and other implementations past the last delimiter, lastEnd.
+// Tables without one are accepted.
+func verifyEnd(report *VerifyReport, index io.ReaderAt, format pkg.IndexFormat, table pkg.DataTable, lastEnd uint64, size uint64) {
+	end, err := format.ReadDataPos(index, table.NumberOfStrings)
+	if err != nil {
+		return
+	}
+	if end.OriginalOffset != size && end.OriginalOffset != lastEnd {
+		report.addAt(CheckEnd, table.NumberOfStrings, "end offset %d is neither past the last delimiter (%d) nor the end of the file (%d)", end.OriginalOffset, lastEnd, size)
+	}
+}
+
+// verifyOrdered checks that listed follows the order strfile -o sorts in.
+// Indexes recording their ordering rules are checked against them; for
+// others, which do not say whether -i was given, either order is accepted.
+func verifyOrdered(report *VerifyReport, table pkg.DataTable, recorded *pkg.Ordering, listed []*scannedEntry) error {
+	orderings := []pkg.Ordering{{}, {IgnoreCase: true}}
+	if recorded != nil {
+		orderings = []pkg.Ordering{*recorded}
+	}
+
+	rot13 := table.Flags&pkg.FlagRotated != 0
+	firstUnsorted := -1
+	for _, ordering := range orderings {
+		compare, err := newComparator(ordering)
+		if err != nil {
+			return err
+		}
+		unsorted := -1
+		var previous *string
+		for i, entry := range listed {
+			if entry == nil {
+				previous = nil
+				continue
+			}
+			current := applyFortuneTransformations(string(entry.text), ordering.IgnoreCase, rot13)
+			if previous != nil && compare(current, *previous) < 0 {
+				unsorted = i
+				break
+			}
+			previous = &current
+		}
+		if unsorted < 0 {
+			return nil
+		}
+		if firstUnsorted < 0 {
+			firstUnsorted = unsorted
+		}
+	}
+	report.addAt(CheckOrder, uint32(firstUnsorted), "string sorts before the one listed ahead of it in an ordered index")
+	return nil
+}
+
+// verifyLengths checks the recorded lengths of listed entries.
+func verifyLengths(report *VerifyReport, lengths []uint32, listed []*scannedEntry) {
+	if len(lengths) != len(listed) {
+		report.add(CheckLengths, "index records %d lengths for %d strings", len(lengths), len(listed))
+		return
+	}
+	for i, entry := range listed {
+		if entry == nil {
+			continue
+		}
+		if length := uint32(len(pkg.RemoveCRLF(entry.text))); lengths[i] != length {
+			report.addAt(CheckLengths, uint32(i), "index records %d bytes, string has %d", lengths[i], length)
+		}
+	}
+}
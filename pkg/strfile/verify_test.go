@@ -0,0 +1,110 @@
+package strfile
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/vromero/gofortune/pkg"
+)
+
+func TestVerifyAcceptsStrFileIndexes(t *testing.T) {
+	tests := map[string]struct{ order, randomize, rot13, largeFile bool }{
+		"plain":     {},
+		"ordered":   {order: true},
+		"random":    {randomize: true},
+		"rot13":     {order: true, rot13: true},
+		"largeFile": {largeFile: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sourceFile, dataFile := writeUnstrSource(t)
+			if _, err := StrFile(sourceFile, dataFile, Options{Order: test.order, Randomize: test.randomize, Rot13: test.rot13, LargeFile: test.largeFile}); err != nil {
+				t.Fatalf("strfile: %v", err)
+			}
+			report, err := Verify(sourceFile, dataFile)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if !report.OK() {
+				t.Errorf("expected no discrepancies, got %+v", report.Discrepancies)
+			}
+		})
+	}
+}
+
+// corruptIndex overwrites the big-endian uint32 at offset of dataFile.
+func corruptIndex(t *testing.T, dataFile string, offset int64, value uint32) {
+	t.Helper()
+	file, err := os.OpenFile(dataFile, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	if _, err := file.WriteAt(binary.BigEndian.AppendUint32(nil, value), offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyReportsDiscrepancies(t *testing.T) {
+	header := int64(pkg.DataTableSize)
+	tests := map[string]struct {
+		offset   int64
+		value    uint32
+		expected VerifyCheck
+	}{
+		"longest":   {8, 3, CheckLongest},
+		"shortest":  {12, 3, CheckShortest},
+		"flags":     {16, pkg.FlagOrdered | pkg.FlagRandom, CheckFlags},
+		"unknown":   {16, 0x100, CheckFlags},
+		"offset":    {header + 4, 3, CheckOffset},
+		"duplicate": {header + 4, 0, CheckOffset},
+		"order":     {header + 4, 30, CheckOrder},
+		"end":       {header + 12, 7, CheckEnd},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sourceFile, dataFile := writeUnstrSource(t)
+			if _, err := StrFile(sourceFile, dataFile, Options{}); err != nil {
+				t.Fatalf("strfile: %v", err)
+			}
+			corruptIndex(t, dataFile, test.offset, test.value)
+
+			report, err := Verify(sourceFile, dataFile)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			found := false
+			for _, discrepancy := range report.Discrepancies {
+				found = found || discrepancy.Check == test.expected
+			}
+			if !found {
+				t.Errorf("expected a %s discrepancy, got %+v", test.expected, report.Discrepancies)
+			}
+		})
+	}
+}
+
+// TestVerifyReportsEditedSource verifies that entries added after indexing
+// are reported through the count and the fingerprint.
+func TestVerifyReportsEditedSource(t *testing.T) {
+	sourceFile, dataFile := writeUnstrSource(t)
+	if _, err := StrFile(sourceFile, dataFile, Options{}); err != nil {
+		t.Fatalf("strfile: %v", err)
+	}
+	if err := os.WriteFile(sourceFile, []byte(unstrSource+"Delta\n%\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(sourceFile, dataFile)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	checks := make(map[VerifyCheck]bool)
+	for _, discrepancy := range report.Discrepancies {
+		checks[discrepancy.Check] = true
+	}
+	if !checks[CheckCount] || !checks[CheckFingerprint] {
+		t.Errorf("expected count and fingerprint discrepancies, got %+v", report.Discrepancies)
+	}
+}